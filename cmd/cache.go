@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/config"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewCacheCommand initializes the cache command
+func NewCacheCommand() *cobra.Command {
+	var configPath, secretName string
+	v := viper.New()
+
+	var command = &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and purge the cached Vault tokens",
+		// The token cache directory can be set in the configuration, like for generate
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return config.ReadSettings(v, &config.Options{
+				SecretName: secretName,
+				ConfigPath: configPath,
+			})
+		},
+		Run: func(cmd *cobra.Command, args []string) {
+			cmd.HelpFunc()(cmd, args)
+		},
+	}
+
+	command.AddCommand(newCacheListCommand())
+	command.AddCommand(newCachePurgeCommand())
+	command.AddCommand(newCacheVerifyCommand(v))
+
+	command.PersistentFlags().StringVarP(&configPath, "config-path", "c", "", "path to a file containing Vault configuration (YAML, JSON, envfile) to use")
+	command.PersistentFlags().StringVarP(&secretName, "secret-name", "s", "", "name of a Kubernetes Secret in the argocd namespace containing Vault configuration data in the argocd namespace of your ArgoCD host (Only available when used in ArgoCD). The namespace can be overridden by using the format <namespace>:<name>")
+	return command
+}
+
+func newCacheListCommand() *cobra.Command {
+	var command = &cobra.Command{
+		Use:          "list",
+		Short:        "List the cached Vault tokens, without revealing them",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := utils.ListTokenCache()
+			if err != nil {
+				return err
+			}
+
+			w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "IDENTIFIER\tVAULT ADDRESS\tNAMESPACE\tTTL")
+			for _, entry := range entries {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", entry.Identifier, entry.VaultAddress, entry.Namespace, remainingTTL(entry))
+			}
+			return w.Flush()
+		},
+	}
+
+	return command
+}
+
+func newCachePurgeCommand() *cobra.Command {
+	var command = &cobra.Command{
+		Use:          "purge [identifier]",
+		Short:        "Remove a cached Vault token, or all of them",
+		Args:         cobra.MaximumNArgs(1),
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				if err := utils.RemoveCachedToken(args[0]); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "removed cached token %s\n", args[0])
+				return nil
+			}
+
+			if err := utils.PurgeTokenCache(); err != nil {
+				return err
+			}
+			fmt.Fprintln(cmd.OutOrStdout(), "removed all cached tokens")
+			return nil
+		},
+	}
+
+	return command
+}
+
+func newCacheVerifyCommand(v *viper.Viper) *cobra.Command {
+	var command = &cobra.Command{
+		Use:          "verify",
+		Short:        "Check that each cached Vault token is still accepted by its Vault server",
+		Args:         cobra.NoArgs,
+		SilenceUsage: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			entries, err := utils.ListTokenCache()
+			if err != nil {
+				return err
+			}
+
+			invalid := writeVerifiedTokens(cmd.OutOrStdout(), v, entries)
+			if invalid > 0 {
+				return fmt.Errorf("found %d invalid cached token(s)", invalid)
+			}
+			return nil
+		},
+	}
+
+	return command
+}
+
+// writeVerifiedTokens looks up every cached token and writes whether it is valid, returning the number of invalid tokens.
+// The Vault clients are configured from the settings in v, like those of the backends that cached the tokens
+func writeVerifiedTokens(out io.Writer, v *viper.Viper, entries []utils.TokenCacheEntry) int {
+	invalid := 0
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "IDENTIFIER\tVAULT ADDRESS\tSTATUS")
+	for _, entry := range entries {
+		status := "valid"
+		apiConfig, err := config.VaultAPIConfig(v, entry.VaultAddress)
+		if err == nil {
+			_, err = entry.LookupSelf(apiConfig)
+		}
+		if err != nil {
+			// Vault API errors span several lines
+			status = "invalid: " + strings.Join(strings.Fields(err.Error()), " ")
+			invalid++
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", entry.Identifier, entry.VaultAddress, status)
+	}
+	w.Flush()
+
+	return invalid
+}
+
+func remainingTTL(entry utils.TokenCacheEntry) string {
+	if entry.ExpiresAt == nil {
+		return "never expires"
+	}
+
+	ttl := time.Until(*entry.ExpiresAt).Round(time.Second)
+	if ttl <= 0 {
+		return "expired"
+	}
+	return ttl.String()
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/hashicorp/vault/api"
+)
+
+func TestCache(t *testing.T) {
+	os.Setenv("AVP_TOKEN_CACHE_DIR", t.TempDir())
+	defer os.Unsetenv("AVP_TOKEN_CACHE_DIR")
+
+	ln, client, roottoken := helpers.CreateTestVault(t)
+	defer ln.Close()
+
+	runCache := func(args ...string) (string, error) {
+		cmd := NewCacheCommand()
+
+		b := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(bytes.NewBufferString(""))
+		err := cmd.Execute()
+		out, _ := io.ReadAll(b) // Read buffer to bytes
+		return string(out), err
+	}
+
+	// Settings are read by the cache command first, like generate does
+	if _, err := runCache("purge"); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SetToken(client, "valid", &api.SecretAuth{ClientToken: roottoken, LeaseDuration: 3600}); err != nil {
+		t.Fatal(err)
+	}
+	if err := utils.SetToken(client, "revoked", &api.SecretAuth{ClientToken: "s.revoked"}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("will list cached tokens without revealing them", func(t *testing.T) {
+		out, err := runCache("list")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 3 || !strings.HasPrefix(lines[0], "IDENTIFIER") {
+			t.Fatalf("expected a header and 2 tokens but got %s", out)
+		}
+		if !strings.HasPrefix(lines[1], "revoked") || !strings.Contains(lines[1], client.Address()) || !strings.HasSuffix(lines[1], "never expires") {
+			t.Errorf("expected the revoked token to never expire but got %s", lines[1])
+		}
+		if !strings.HasPrefix(lines[2], "valid") || !strings.Contains(lines[2], client.Address()) || !(strings.HasSuffix(lines[2], "1h0m0s") || strings.Contains(lines[2], "59m59s")) {
+			t.Errorf("expected the valid token to expire in an hour but got %s", lines[2])
+		}
+		if strings.Contains(out, roottoken) || strings.Contains(out, "s.revoked") {
+			t.Errorf("expected no token in %s", out)
+		}
+	})
+
+	t.Run("will verify cached tokens", func(t *testing.T) {
+		out, err := runCache("verify")
+		expected := "found 1 invalid cached token(s)"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got: %v", expected, err)
+		}
+
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		if len(lines) != 3 || !strings.Contains(lines[1], "invalid: ") || !strings.HasSuffix(lines[2], "valid") {
+			t.Errorf("expected the revoked token only to be invalid but got %s", out)
+		}
+	})
+
+	t.Run("will purge a single cached token", func(t *testing.T) {
+		out, err := runCache("purge", "revoked")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "removed cached token revoked\n" {
+			t.Errorf("unexpected output %s", out)
+		}
+
+		if _, err := runCache("verify"); err != nil {
+			t.Errorf("expected no invalid cached token but got: %s", err)
+		}
+
+		_, err = runCache("purge", "revoked")
+		expected := "No cached token revoked"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %s but got: %v", expected, err)
+		}
+	})
+
+	t.Run("will purge all cached tokens", func(t *testing.T) {
+		out, err := runCache("purge")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if out != "removed all cached tokens\n" {
+			t.Errorf("unexpected output %s", out)
+		}
+
+		out, err = runCache("list")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if strings.Count(out, "\n") != 1 {
+			t.Errorf("expected no cached token but got %s", out)
+		}
+	})
+}
@@ -8,6 +8,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/backends"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/config"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/kube"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
@@ -22,6 +23,11 @@ func NewGenerateCommand() *cobra.Command {
 	var configPath, secretName string
 	var verboseOutput bool
 	var disableCache bool
+	var disableSecretCache bool
+	var prefetchWorkers int
+	var dryRun bool
+	var keepGoing bool
+	var reportPath string
 
 	var command = &cobra.Command{
 		Use:   "generate <path>",
@@ -78,29 +84,83 @@ func NewGenerateCommand() *cobra.Command {
 				return err
 			}
 
-			for _, manifest := range manifests {
-				var pathValidation *regexp.Regexp
-				if rexp := v.GetString(types.EnvPathValidation); rexp != "" {
-					pathValidation, err = regexp.Compile(rexp)
-					if err != nil {
-						return fmt.Errorf("%s is not a valid regular expression: %s", rexp, err)
+			var pathValidation *regexp.Regexp
+			if rexp := v.GetString(types.EnvPathValidation); rexp != "" {
+				pathValidation, err = regexp.Compile(rexp)
+				if err != nil {
+					return fmt.Errorf("%s is not a valid regular expression: %s", rexp, err)
+				}
+			}
+
+			backend := cmdConfig.Backend
+			if !disableSecretCache {
+				cache := backends.NewCacheBackend(backend)
+				if prefetchWorkers > 0 {
+					var refs []types.SecretRef
+					for _, manifest := range manifests {
+						for _, ref := range kube.SecretRefs(manifest) {
+							// Disallowed paths are reported during replacement, they must never reach the backend
+							if pathValidation == nil || pathValidation.MatchString(ref.Path) {
+								refs = append(refs, ref)
+							}
+						}
 					}
+					cache.Prefetch(refs, prefetchWorkers)
 				}
+				backend = cache
+			}
 
-				template, err := kube.NewTemplate(manifest, cmdConfig.Backend, pathValidation)
+			// Replace the placeholders of every manifest first, checksums are computed from other manifests
+			templates := make([]*kube.Template, len(manifests))
+			errs := make([]error, len(manifests))
+			ignored := make([]bool, len(manifests))
+			var rendered []*kube.Template
+			for idx, manifest := range manifests {
+				template, err := kube.NewTemplate(manifest, backend, pathValidation)
 				if err != nil {
-					return err
+					errs[idx] = err
+					continue
 				}
+				template.Redact = dryRun
+				templates[idx] = template
 
 				annotations := manifest.GetAnnotations()
 				avpIgnore, _ := strconv.ParseBool(annotations[types.AVPIgnoreAnnotation])
+				ignored[idx] = avpIgnore
 				if !avpIgnore {
-					err = template.Replace()
-					if err != nil {
-						return err
-					}
+					errs[idx] = template.Replace()
 				} else {
 					utils.VerboseToStdErr("skipping %s.%s because %s annotation is present", manifest.GetNamespace(), manifest.GetName(), types.AVPIgnoreAnnotation)
+					template.Skip()
+				}
+
+				if errs[idx] == nil {
+					rendered = append(rendered, template)
+				}
+			}
+
+			var outputs []string
+			var failures []string
+			report := &generateReport{}
+			for idx, manifest := range manifests {
+				template, err := templates[idx], errs[idx]
+				// Ignored manifests are output as they are, annotations included
+				if err == nil && !ignored[idx] {
+					err = template.InjectChecksum(rendered)
+				}
+
+				var resolutions []kube.Resolution
+				if template != nil {
+					resolutions = template.Resolutions()
+				}
+				report.add(manifest, resolutions, err)
+
+				if err != nil {
+					failures = append(failures, manifestFailure(manifest, err))
+					// A dry run shows the failed manifests too, along with their summary
+					if template == nil || !dryRun {
+						continue
+					}
 				}
 
 				output, err := template.ToYAML()
@@ -108,9 +168,31 @@ func NewGenerateCommand() *cobra.Command {
 					return err
 				}
 
-				fmt.Fprintf(cmd.OutOrStdout(), "%s---\n", output)
+				if dryRun {
+					output = dryRunSummary(manifest, resolutions) + output
+				}
+				outputs = append(outputs, output)
 			}
 
+			if reportPath != "" {
+				err = report.write(reportPath)
+				if err != nil {
+					return err
+				}
+			}
+
+			// Unless asked otherwise, only output complete sets of manifests
+			if len(failures) == 0 || keepGoing || dryRun {
+				for _, output := range outputs {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s---\n", output)
+				}
+			}
+
+			if len(failures) != 0 {
+				// The usage would bury the errors, which are not caused by the arguments
+				cmd.SilenceUsage = true
+				return fmt.Errorf("could not generate %d of %d manifest(s):\n%s", len(failures), len(manifests), strings.Join(failures, "\n"))
+			}
 			return nil
 		},
 	}
@@ -119,5 +201,43 @@ func NewGenerateCommand() *cobra.Command {
 	command.Flags().StringVarP(&secretName, "secret-name", "s", "", "name of a Kubernetes Secret in the argocd namespace containing Vault configuration data in the argocd namespace of your ArgoCD host (Only available when used in ArgoCD). The namespace can be overridden by using the format <namespace>:<name>")
 	command.Flags().BoolVar(&verboseOutput, "verbose-sensitive-output", false, "enable verbose mode for detailed info to help with debugging. Includes sensitive data (credentials), logged to stderr")
 	command.Flags().BoolVar(&disableCache, "disable-token-cache", false, "disable the automatic token cache feature that store tokens locally")
+	command.Flags().BoolVar(&disableSecretCache, "disable-secret-cache", false, "look secrets up every time a manifest refers to them instead of once per run, also disables prefetching")
+	command.Flags().IntVar(&prefetchWorkers, "prefetch-workers", 10, "number of concurrent lookups used to fetch all referenced secrets before replacing placeholders, 0 disables prefetching")
+	command.Flags().BoolVar(&dryRun, "dry-run", false, "resolve placeholders against the backend but mask the secret values with a checksum, and print a summary of each manifest's placeholders")
+	command.Flags().BoolVar(&keepGoing, "keep-going", false, "output the manifests that could be generated even if others failed, the command still fails")
+	command.Flags().StringVar(&reportPath, "report", "", "write a JSON report of the placeholders of every manifest, where they are looked up and whether they were resolved, to this file. Secret values are never included")
 	return command
 }
+
+// manifestID identifies a manifest in messages, as `Kind namespace/name`
+func manifestID(manifest unstructured.Unstructured) string {
+	name := manifest.GetName()
+	if namespace := manifest.GetNamespace(); namespace != "" {
+		name = namespace + "/" + name
+	}
+	return manifest.GetKind() + " " + name
+}
+
+// manifestFailure describes the error generating a manifest, with its lines indented under the manifest
+func manifestFailure(manifest unstructured.Unstructured, err error) string {
+	return fmt.Sprintf("%s:\n  %s", manifestID(manifest), strings.ReplaceAll(err.Error(), "\n", "\n  "))
+}
+
+// dryRunSummary describes how the placeholders of a manifest were handled, as YAML comments
+func dryRunSummary(manifest unstructured.Unstructured, resolutions []kube.Resolution) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s: %d placeholder(s)\n", manifestID(manifest), len(resolutions))
+
+	for _, r := range resolutions {
+		secret := r.Path + "#" + r.SecretKey
+		if r.Version != "" {
+			secret += "#" + r.Version
+		}
+		if r.Backend != "" {
+			secret = r.Backend + ":" + secret
+		}
+		fmt.Fprintf(&b, "#   %s: <%s> in %s (%s)\n", r.Outcome, r.Placeholder, r.Key, secret)
+	}
+
+	return b.String()
+}
@@ -2,9 +2,9 @@ package cmd
 
 import (
 	"bytes"
-	"fmt"
 	"io"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
@@ -142,6 +142,295 @@ func TestMain(t *testing.T) {
 		}
 	})
 
+	t.Run("will replace templates from local vault without prefetching", func(t *testing.T) {
+		args := []string{"../fixtures/input/nonempty", "--prefetch-workers", "0"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		cmd.Execute()
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr, err := io.ReadAll(e) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := os.ReadFile("../fixtures/output/all.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := string(buf)
+		if string(out) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(out), string(stderr))
+		}
+	})
+
+	t.Run("will replace templates from local vault without caching", func(t *testing.T) {
+		args := []string{"../fixtures/input/nonempty", "--disable-secret-cache"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		cmd.Execute()
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr, err := io.ReadAll(e) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := os.ReadFile("../fixtures/output/all.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := string(buf)
+		if string(out) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(out), string(stderr))
+		}
+	})
+
+	t.Run("will redact secret values in dry run", func(t *testing.T) {
+		args := []string{"../fixtures/input/nonempty/secret_remove_missing.yaml", "--dry-run"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		cmd.Execute()
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr, err := io.ReadAll(e) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `# Secret default/my-app: 2 placeholder(s)
+#   removed: <missing-value> in MISSING_VALUE (kv/data/testing#missing-value)
+#   resolved: <target-port> in PORT (kv/data/testing#target-port)
+apiVersion: v1
+data:
+  PORT: '***sha256:48449a14***'
+kind: Secret
+metadata:
+  annotations:
+    avp.kubernetes.io/path: kv/data/testing
+    avp.kubernetes.io/remove-missing: "true"
+  name: my-app
+  namespace: default
+---
+`
+		if string(out) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(out), string(stderr))
+		}
+	})
+
+	t.Run("will summarize every manifest in dry run", func(t *testing.T) {
+		args := []string{"../fixtures/input/nonempty", "--dry-run"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		cmd.Execute()
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+		stderr, err := io.ReadAll(e) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Contains(string(out), "test-kv-name") {
+			t.Fatalf("expected secret values to be redacted but got %s", string(out))
+		}
+		if !strings.Contains(string(out), "#   resolved: <name> in name (kv/data/testing#name)") {
+			t.Fatalf("expected a summary of the placeholders but got %s", string(out))
+		}
+		if strings.Contains(string(stderr), "Error:") {
+			t.Fatalf("expected no errors but got %s", string(stderr))
+		}
+	})
+
+	t.Run("will report the errors of every manifest", func(t *testing.T) {
+		args := []string{"../fixtures/input/partial"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		err := cmd.Execute()
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(out) != 0 {
+			t.Fatalf("expected no output but got %s", string(out))
+		}
+
+		expected := []string{
+			"could not generate 2 of 3 manifest(s):",
+			"Secret default/missing-keys:\n  Replace: could not replace all placeholders in Template:\n",
+			"\n  replaceString: missing Vault value for placeholder first-missing in string FIRST: <first-missing>",
+			"\n  replaceString: missing Vault value for placeholder second-missing in string SECOND: <second-missing>",
+			"Secret default/unknown-path:\n  Could not find secrets at path kv/data/unknown",
+		}
+		stderr := e.String()
+		for _, message := range expected {
+			if !strings.Contains(stderr, message) {
+				t.Fatalf("expected %s to contain %s", stderr, message)
+			}
+		}
+	})
+
+	t.Run("will output the successful manifests with --keep-going", func(t *testing.T) {
+		args := []string{"../fixtures/input/partial", "--keep-going"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		err := cmd.Execute()
+		if err == nil {
+			t.Fatal("expected an error")
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `apiVersion: v1
+data:
+  NAME: test-kv-name
+kind: ConfigMap
+metadata:
+  annotations:
+    avp.kubernetes.io/path: kv/data/testing
+  name: example-configmap
+  namespace: default
+---
+`
+		if string(out) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(out), e.String())
+		}
+		if !strings.Contains(e.String(), "could not generate 2 of 3 manifest(s)") {
+			t.Fatalf("expected the failed manifests to be reported but got %s", e.String())
+		}
+	})
+
+	t.Run("will write a report of the placeholders", func(t *testing.T) {
+		reportPath := filepath.Join(t.TempDir(), "report.json")
+		args := []string{"../fixtures/input/report", "--report", reportPath}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		report, err := os.ReadFile(reportPath)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := os.ReadFile("../fixtures/output/report.json")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := string(buf)
+		if string(report) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(report), e.String())
+		}
+	})
+
+	t.Run("will annotate workloads with the checksum of other manifests", func(t *testing.T) {
+		args := []string{"../fixtures/input/checksum"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		cmd.Execute()
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := os.ReadFile("../fixtures/output/checksum.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := string(buf)
+		if string(out) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(out), e.String())
+		}
+	})
+
+	t.Run("will not annotate ignored workloads with a checksum", func(t *testing.T) {
+		args := []string{"../fixtures/input/ignored-checksum.yaml"}
+		cmd := NewGenerateCommand()
+
+		b := bytes.NewBufferString("")
+		e := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(e)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		buf, err := os.ReadFile("../fixtures/output/ignored-checksum.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := string(buf)
+		if string(out) != expected {
+			t.Fatalf("expected %s\n\nbut got\n\n%s\nerr: %s", expected, string(out), e.String())
+		}
+	})
+
 	t.Run("will ignore templates with avp.kubernetes.io/ignore set to True", func(t *testing.T) {
 		args := []string{"../fixtures/input/nonempty/ignored-secret.yaml"}
 		cmd := NewGenerateCommand()
@@ -198,6 +487,47 @@ func TestMain(t *testing.T) {
 		}
 	})
 
+	t.Run("will replace KV-V2 metadata as strings", func(t *testing.T) {
+		stdin := bytes.NewBufferString(`apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: example-deployment
+  annotations:
+    secret-version: <path:kv/data/testing#@metadata.version>
+spec:
+  replicas: <path:kv/data/testing#replicas>
+`)
+
+		args := []string{"-"}
+		cmd := NewGenerateCommand()
+
+		stdout := bytes.NewBufferString("")
+		stderr := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(stdout)
+		cmd.SetErr(stderr)
+		cmd.SetIn(stdin)
+		cmd.Execute()
+		out, err := io.ReadAll(stdout) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  annotations:
+    secret-version: "1"
+  name: example-deployment
+spec:
+  replicas: "3"
+---
+`
+		if string(out) != expected {
+			t.Fatalf("expected %s but got %s\nerr: %s", expected, string(out), stderr.String())
+		}
+	})
+
 	t.Run("will return invalid yaml error from STDIN", func(t *testing.T) {
 		stdin := bytes.NewBufferString("")
 		inputBuf, err := os.ReadFile("../fixtures/input/invalid.yaml")
@@ -294,7 +624,7 @@ func TestMain(t *testing.T) {
 		}
 
 		// No cache is expected
-		_, err = utils.ReadExistingToken(fmt.Sprintf("approle_%s", roleid))
+		_, err = utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, "auth/approle", roleid))
 		if err == nil {
 			t.Fatalf("expected no cache but found one")
 		}
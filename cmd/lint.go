@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/config"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/kube"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// NewLintCommand initializes the lint command
+func NewLintCommand() *cobra.Command {
+	const StdIn = "-"
+	var configPath, secretName string
+
+	var command = &cobra.Command{
+		Use:          "lint <path>",
+		Short:        "Check manifests for placeholder and annotation mistakes without contacting a secret manager",
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("<path> argument required to lint manifests")
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputs := make(map[string][]byte)
+			var names []string
+
+			path := args[0]
+			if path == StdIn {
+				rawdata, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				inputs["<stdin>"] = rawdata
+				names = append(names, "<stdin>")
+			} else {
+				files, err := listFiles(path)
+				if len(files) < 1 {
+					return fmt.Errorf("no YAML or JSON files were found in %s", path)
+				}
+				if err != nil {
+					return err
+				}
+
+				for _, file := range files {
+					rawdata, err := os.ReadFile(file)
+					if err != nil {
+						return fmt.Errorf("could not read file: %s from disk: %s", file, err)
+					}
+					inputs[file] = rawdata
+					names = append(names, file)
+				}
+			}
+
+			v := viper.New()
+			err := config.ReadSettings(v, &config.Options{
+				SecretName: secretName,
+				ConfigPath: configPath,
+			})
+			if err != nil {
+				return err
+			}
+
+			var pathValidation *regexp.Regexp
+			if rexp := v.GetString(types.EnvPathValidation); rexp != "" {
+				pathValidation, err = regexp.Compile(rexp)
+				if err != nil {
+					return fmt.Errorf("%s is not a valid regular expression: %s", rexp, err)
+				}
+			}
+
+			// The checksum-from annotation may name manifests of any other file
+			checksumIssues := kube.LintChecksumSources(inputs)
+
+			problems := 0
+			for _, name := range names {
+				// Report anything generate would be unable to read first
+				if _, err := readManifestData(bytes.NewReader(inputs[name])); err != nil {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", name, err)
+					problems++
+					continue
+				}
+
+				issues, err := kube.Lint(inputs[name], pathValidation)
+				for _, issue := range append(issues, checksumIssues[name]...) {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s:%s\n", name, issue)
+					if !issue.Warning {
+						problems++
+					}
+				}
+				if err != nil {
+					fmt.Fprintf(cmd.OutOrStdout(), "%s: %s\n", name, err)
+					problems++
+				}
+			}
+
+			if problems > 0 {
+				return fmt.Errorf("found %d problem(s)", problems)
+			}
+			return nil
+		},
+	}
+
+	command.Flags().StringVarP(&configPath, "config-path", "c", "", "path to a file containing Vault configuration (YAML, JSON, envfile) to use")
+	command.Flags().StringVarP(&secretName, "secret-name", "s", "", "name of a Kubernetes Secret in the argocd namespace containing Vault configuration data in the argocd namespace of your ArgoCD host (Only available when used in ArgoCD). The namespace can be overridden by using the format <namespace>:<name>")
+	return command
+}
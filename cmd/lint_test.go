@@ -0,0 +1,118 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("will report problems with their locations", func(t *testing.T) {
+		os.Setenv("AVP_PATH_VALIDATION", `^([A-Za-z/]*)$`)
+		t.Cleanup(func() {
+			os.Unsetenv("AVP_PATH_VALIDATION")
+		})
+
+		args := []string{"../fixtures/input/lint/problems.yaml"}
+		cmd := NewLintCommand()
+
+		stdout := bytes.NewBufferString("")
+		stderr := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(stdout)
+		cmd.SetErr(stderr)
+		err := cmd.Execute()
+		if err == nil {
+			t.Fatalf("expected lint to fail")
+		}
+
+		out, err := io.ReadAll(stdout) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join([]string{
+			"../fixtures/input/lint/problems.yaml:6: avp.kubernetes.io/remove-missing annotation can only be used on Secret or ConfigMap resources, not Deployment",
+			"../fixtures/input/lint/problems.yaml:12: malformed placeholder <path:secret/images>, expected <path:some/path#key> or <path:some/path#key#version>",
+			"../fixtures/input/lint/problems.yaml:15: generic placeholder <log-level> will not be replaced because the manifest has no avp.kubernetes.io/path annotation",
+			"../fixtures/input/lint/problems.yaml:22: the path ../secret/testing is disallowed by AVP_PATH_VALIDATION restriction",
+			"../fixtures/input/lint/problems.yaml:25: invalid modifier: upcase for placeholder username",
+			"../fixtures/input/lint/problems.yaml:26: unterminated placeholder <path:secret/testing#password",
+		}, "\n") + "\n"
+		if string(out) != expected {
+			t.Fatalf("expected %s but got %s", expected, string(out))
+		}
+
+		errOut, err := io.ReadAll(stderr) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(errOut), "found 6 problem(s)") {
+			t.Fatalf("expected to contain: found 6 problem(s) but got %s", errOut)
+		}
+	})
+
+	t.Run("will accept valid manifests", func(t *testing.T) {
+		args := []string{"../fixtures/input/lint/valid.yaml"}
+		cmd := NewLintCommand()
+
+		stdout := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(stdout)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		if stdout.Len() != 0 {
+			t.Fatalf("expected no output but got %s", stdout.String())
+		}
+	})
+
+	t.Run("will warn about htpasswd in manifests named by checksum-from", func(t *testing.T) {
+		args := []string{"../fixtures/input/lint/checksum"}
+		cmd := NewLintCommand()
+
+		stdout := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(stdout)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := "../fixtures/input/lint/checksum/secret.yaml:9: warning: htpasswd modifier hashes with a random salt, so the manifest is different on every run and restarts the pods naming it in their avp.kubernetes.io/checksum-from annotation\n"
+		if stdout.String() != expected {
+			t.Fatalf("expected %s but got %s", expected, stdout.String())
+		}
+	})
+
+	t.Run("will report invalid yaml from STDIN", func(t *testing.T) {
+		stdin := bytes.NewBufferString("")
+		inputBuf, err := os.ReadFile("../fixtures/input/invalid.yaml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		stdin.Write(inputBuf)
+
+		args := []string{"-"}
+		cmd := NewLintCommand()
+
+		stdout := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(stdout)
+		cmd.SetErr(bytes.NewBufferString(""))
+		cmd.SetIn(stdin)
+		err = cmd.Execute()
+		if err == nil {
+			t.Fatalf("expected lint to fail")
+		}
+
+		expected := "<stdin>: error converting YAML to JSON: yaml: line 18: did not find expected key"
+		if strings.TrimSpace(stdout.String()) != expected {
+			t.Fatalf("expected %s but got %s", expected, stdout.String())
+		}
+	})
+}
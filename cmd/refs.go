@@ -0,0 +1,142 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/kube"
+	"github.com/spf13/cobra"
+)
+
+// secretRef is a single placeholder found by `refs`
+type secretRef struct {
+	File      string   `json:"file"`
+	Kind      string   `json:"kind"`
+	Namespace string   `json:"namespace,omitempty"`
+	Name      string   `json:"name"`
+	Field     string   `json:"field"`
+	Backend   string   `json:"backend,omitempty"`
+	Path      string   `json:"path"`
+	Key       string   `json:"key"`
+	Version   string   `json:"version,omitempty"`
+	Modifiers []string `json:"modifiers,omitempty"`
+}
+
+var refsHeader = []string{"FILE", "KIND", "NAMESPACE", "NAME", "FIELD", "BACKEND", "PATH", "KEY", "VERSION"}
+
+func (r secretRef) columns() []string {
+	return []string{r.File, r.Kind, r.Namespace, r.Name, r.Field, r.Backend, r.Path, r.Key, r.Version}
+}
+
+// NewRefsCommand initializes the refs command
+func NewRefsCommand() *cobra.Command {
+	const StdIn = "-"
+	var output string
+
+	var command = &cobra.Command{
+		Use:          "refs <path>",
+		Short:        "List the secret paths and keys referenced by manifests without contacting a secret manager",
+		SilenceUsage: true,
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) < 1 {
+				return fmt.Errorf("<path> argument required to list secret references")
+			}
+			if output != "table" && output != "json" && output != "csv" {
+				return fmt.Errorf("unsupported output format %s, use table, json or csv", output)
+			}
+			return nil
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inputs := make(map[string][]byte)
+			var names []string
+
+			path := args[0]
+			if path == StdIn {
+				rawdata, err := io.ReadAll(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				inputs["<stdin>"] = rawdata
+				names = append(names, "<stdin>")
+			} else {
+				files, err := listFiles(path)
+				if len(files) < 1 {
+					return fmt.Errorf("no YAML or JSON files were found in %s", path)
+				}
+				if err != nil {
+					return err
+				}
+
+				for _, file := range files {
+					rawdata, err := os.ReadFile(file)
+					if err != nil {
+						return fmt.Errorf("could not read file: %s from disk: %s", file, err)
+					}
+					inputs[file] = rawdata
+					names = append(names, file)
+				}
+			}
+
+			refs := []secretRef{}
+			for _, name := range names {
+				manifests, err := readManifestData(bytes.NewReader(inputs[name]))
+				if err != nil {
+					return fmt.Errorf("could not read %s: %s", name, err)
+				}
+
+				for _, manifest := range manifests {
+					for _, placeholder := range kube.Placeholders(manifest) {
+						refs = append(refs, secretRef{
+							File:      name,
+							Kind:      manifest.GetKind(),
+							Namespace: manifest.GetNamespace(),
+							Name:      manifest.GetName(),
+							Field:     placeholder.Key,
+							Backend:   placeholder.Backend,
+							Path:      placeholder.Path,
+							Key:       placeholder.SecretKey,
+							Version:   placeholder.Version,
+							Modifiers: placeholder.Modifiers,
+						})
+					}
+				}
+			}
+
+			return writeRefs(cmd.OutOrStdout(), output, refs)
+		},
+	}
+
+	command.Flags().StringVarP(&output, "output", "o", "table", "output format, one of table, json or csv")
+	return command
+}
+
+func writeRefs(out io.Writer, format string, refs []secretRef) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(out)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(refs)
+	case "csv":
+		w := csv.NewWriter(out)
+		w.Write(refsHeader)
+		for _, ref := range refs {
+			w.Write(ref.columns())
+		}
+		w.Flush()
+		return w.Error()
+	default:
+		w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(refsHeader, "\t"))
+		for _, ref := range refs {
+			fmt.Fprintln(w, strings.Join(ref.columns(), "\t"))
+		}
+		return w.Flush()
+	}
+}
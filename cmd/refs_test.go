@@ -0,0 +1,158 @@
+package cmd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestRefs(t *testing.T) {
+	t.Run("will list references as a table", func(t *testing.T) {
+		args := []string{"../fixtures/input/report"}
+		cmd := NewRefsCommand()
+
+		b := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join([]string{
+			"FILE                                          KIND       NAMESPACE  NAME               FIELD          BACKEND  PATH             KEY            VERSION",
+			"../fixtures/input/report/configmap.yaml       ConfigMap  default    example-configmap  NAME                    kv/data/testing  name           ",
+			"../fixtures/input/report/configmap.yaml       ConfigMap  default    example-configmap  TAG                     kv/data/testing  tag            ",
+			"../fixtures/input/report/remove-missing.yaml  Secret     default    remove-missing     MISSING_VALUE           kv/data/testing  missing-value  ",
+			"../fixtures/input/report/remove-missing.yaml  Secret     default    remove-missing     PORT                    kv/data/testing  target-port    ",
+		}, "\n") + "\n"
+		if string(out) != expected {
+			t.Fatalf("expected %s but got %s", expected, string(out))
+		}
+	})
+
+	t.Run("will list references as CSV", func(t *testing.T) {
+		args := []string{"../fixtures/input/nonempty/secret_path.yaml", "--output", "csv"}
+		cmd := NewRefsCommand()
+
+		b := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join([]string{
+			"FILE,KIND,NAMESPACE,NAME,FIELD,BACKEND,PATH,KEY,VERSION",
+			"../fixtures/input/nonempty/secret_path.yaml,Secret,default,example-secret,SECRET_VAR,,secret/testing,secret-var-value,",
+			"../fixtures/input/nonempty/secret_path.yaml,Secret,default,example-secret,SECRET_VAR_VERSIONED,,secret/testing,secret-var-value,version",
+		}, "\n") + "\n"
+		if string(out) != expected {
+			t.Fatalf("expected %s but got %s", expected, string(out))
+		}
+	})
+
+	t.Run("will list references from STDIN as JSON", func(t *testing.T) {
+		args := []string{"-", "-o", "json"}
+		cmd := NewRefsCommand()
+
+		b := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetIn(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: example-secret
+stringData:
+  PASSWORD: <corp:path:secret/db#password#2 | base64encode>
+`))
+		cmd.SetOut(b)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := `[
+  {
+    "file": "<stdin>",
+    "kind": "Secret",
+    "name": "example-secret",
+    "field": "PASSWORD",
+    "backend": "corp",
+    "path": "secret/db",
+    "key": "password",
+    "version": "2",
+    "modifiers": [
+      "base64encode"
+    ]
+  }
+]
+`
+		if string(out) != expected {
+			t.Fatalf("expected %s but got %s", expected, string(out))
+		}
+	})
+
+	t.Run("will list the secrets of inject-all", func(t *testing.T) {
+		args := []string{"-", "-o", "csv"}
+		cmd := NewRefsCommand()
+
+		b := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetIn(strings.NewReader(`apiVersion: v1
+kind: Secret
+metadata:
+  name: example-secret
+  annotations:
+    avp.kubernetes.io/path: kv/data/app
+    avp.kubernetes.io/secret-version: "2"
+    avp.kubernetes.io/inject-all: "true"
+`))
+		cmd.SetOut(b)
+		err := cmd.Execute()
+		if err != nil {
+			t.Fatal(err)
+		}
+		out, err := io.ReadAll(b) // Read buffer to bytes
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := strings.Join([]string{
+			"FILE,KIND,NAMESPACE,NAME,FIELD,BACKEND,PATH,KEY,VERSION",
+			"<stdin>,Secret,,example-secret,data,,kv/data/app,*,2",
+		}, "\n") + "\n"
+		if string(out) != expected {
+			t.Fatalf("expected %s but got %s", expected, string(out))
+		}
+	})
+
+	t.Run("will reject unknown output formats", func(t *testing.T) {
+		args := []string{"../fixtures/input/report", "-o", "xml"}
+		cmd := NewRefsCommand()
+
+		b := bytes.NewBufferString("")
+		cmd.SetArgs(args)
+		cmd.SetOut(b)
+		cmd.SetErr(b)
+		err := cmd.Execute()
+
+		expected := "unsupported output format xml, use table, json or csv"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/kube"
+)
+
+// generateReport describes the placeholders of every manifest handled by `generate`, never their values
+type generateReport struct {
+	Manifests []manifestReport `json:"manifests"`
+}
+
+type manifestReport struct {
+	APIVersion   string            `json:"apiVersion"`
+	Kind         string            `json:"kind"`
+	Namespace    string            `json:"namespace,omitempty"`
+	Name         string            `json:"name"`
+	Placeholders []kube.Resolution `json:"placeholders"`
+	Error        string            `json:"error,omitempty"`
+}
+
+func (r *generateReport) add(manifest unstructured.Unstructured, resolutions []kube.Resolution, err error) {
+	entry := manifestReport{
+		APIVersion:   manifest.GetAPIVersion(),
+		Kind:         manifest.GetKind(),
+		Namespace:    manifest.GetNamespace(),
+		Name:         manifest.GetName(),
+		Placeholders: resolutions,
+	}
+	if entry.Placeholders == nil {
+		entry.Placeholders = []kube.Resolution{}
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	r.Manifests = append(r.Manifests, entry)
+}
+
+func (r *generateReport) write(path string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not serialize report: %s", err)
+	}
+
+	err = os.WriteFile(path, append(data, '\n'), 0644)
+	if err != nil {
+		return fmt.Errorf("could not write report to %s: %s", path, err)
+	}
+	return nil
+}
@@ -14,7 +14,10 @@ func NewRootCommand() *cobra.Command {
 		},
 	}
 
+	command.AddCommand(NewCacheCommand())
 	command.AddCommand(NewGenerateCommand())
+	command.AddCommand(NewLintCommand())
+	command.AddCommand(NewRefsCommand())
 	command.AddCommand(NewVersionCommand())
 
 	return command
@@ -0,0 +1,96 @@
+package vault
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	azureMountPath = "auth/azure"
+	azureResource  = "https://management.azure.com/"
+)
+
+// AzureAuth is a struct for working with Vault that uses the Azure managed or workload identity of the plugin
+type AzureAuth struct {
+	Role string
+
+	// Optional, must match the resource configured in Vault, defaults to https://management.azure.com/
+	Resource string
+
+	// Optional, only needed by roles bound to subscriptions or resource groups
+	SubscriptionID    string
+	ResourceGroupName string
+
+	MountPath  string
+	Credential azcore.TokenCredential
+}
+
+// NewAzureAuth initializes a new AzureAuth getting its access tokens from credential
+func NewAzureAuth(role, resource, subscriptionID, resourceGroupName, mountPath string, credential azcore.TokenCredential) *AzureAuth {
+	azureAuth := &AzureAuth{
+		Role:              role,
+		Resource:          azureResource,
+		SubscriptionID:    subscriptionID,
+		ResourceGroupName: resourceGroupName,
+		MountPath:         azureMountPath,
+		Credential:        credential,
+	}
+	if resource != "" {
+		azureAuth.Resource = resource
+	}
+	if mountPath != "" {
+		azureAuth.MountPath = mountPath
+	}
+
+	return azureAuth
+}
+
+// Authenticate authenticates with Vault using an Azure access token and returns a token
+func (a *AzureAuth) Authenticate(vaultClient *api.Client) error {
+	tokenID := utils.TokenCacheID(vaultClient, a.MountPath, a.Role)
+	err := utils.LoginWithCachedToken(vaultClient, tokenID)
+	if err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot retrieve cached token: %v. Generating a new one", err)
+	} else {
+		return nil
+	}
+
+	token, err := a.Credential.GetToken(context.TODO(), policy.TokenRequestOptions{
+		Scopes: []string{strings.TrimSuffix(a.Resource, "/") + "/.default"},
+	})
+	if err != nil {
+		return fmt.Errorf("could not get Azure access token: %s", err)
+	}
+
+	payload := map[string]interface{}{
+		"role": a.Role,
+		"jwt":  token.Token,
+	}
+	if a.SubscriptionID != "" {
+		payload["subscription_id"] = a.SubscriptionID
+	}
+	if a.ResourceGroupName != "" {
+		payload["resource_group_name"] = a.ResourceGroupName
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authenticating with Vault role %s using Azure access token %s", a.Role, token.Token)
+	data, err := vaultClient.Logical().Write(fmt.Sprintf("%s/login", a.MountPath), payload)
+	if err != nil {
+		return err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authentication response: %v", data)
+
+	// If we cannot write the Vault token, we'll just have to login next time. Nothing showstopping.
+	if err = utils.SetToken(vaultClient, tokenID, data.Auth); err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot cache token for future runs: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/policy"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/auth/vault"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+// azureCredential hands out a fixed access token, recording the scopes it was asked for
+type azureCredential struct {
+	scopes []string
+}
+
+func (c *azureCredential) GetToken(ctx context.Context, options policy.TokenRequestOptions) (azcore.AccessToken, error) {
+	c.scopes = options.Scopes
+	return azcore.AccessToken{Token: "access-token", ExpiresOn: time.Now().Add(time.Hour)}, nil
+}
+
+func TestAzureAuth(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	credential := &azureCredential{}
+	azure := vault.NewAzureAuth("argocd", "", "subscription", "group", "", credential)
+
+	err := azure.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	if len(credential.scopes) != 1 || credential.scopes[0] != "https://management.azure.com/.default" {
+		t.Fatalf("expected a token for https://management.azure.com/.default but got %v", credential.scopes)
+	}
+
+	cachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, azure.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	err = azure.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	newCachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, azure.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	if bytes.Compare(cachedToken, newCachedToken) != 0 {
+		t.Fatalf("expected same token %s but got %s", cachedToken, newCachedToken)
+	}
+}
@@ -0,0 +1,84 @@
+package vault
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net/http"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	certMountPath = "auth/cert"
+)
+
+// CertAuth is a struct for working with Vault that uses the TLS client certificate of the Vault client
+type CertAuth struct {
+	// Optional, Vault tries every role matching the certificate if left blank
+	Role      string
+	MountPath string
+}
+
+// NewCertAuth initializes a new CertAuth with the name of the role to log in with
+func NewCertAuth(role, mountPath string) *CertAuth {
+	certAuth := &CertAuth{
+		Role:      role,
+		MountPath: certMountPath,
+	}
+	if mountPath != "" {
+		certAuth.MountPath = mountPath
+	}
+
+	return certAuth
+}
+
+// Authenticate authenticates with Vault using the client certificate and returns a token
+func (a *CertAuth) Authenticate(vaultClient *api.Client) error {
+	tokenID := utils.TokenCacheID(vaultClient, a.MountPath, a.Role)
+	err := utils.LoginWithCachedToken(vaultClient, tokenID)
+	if err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot retrieve cached token: %v. Generating a new one", err)
+	} else {
+		return nil
+	}
+
+	payload := map[string]interface{}{}
+	if a.Role != "" {
+		payload["name"] = a.Role
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authenticating with client certificate and role %s", a.Role)
+	data, err := vaultClient.Logical().Write(fmt.Sprintf("%s/login", a.MountPath), payload)
+	if err != nil {
+		return err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authentication response: %v", data)
+
+	// If we cannot write the Vault token, we'll just have to login next time. Nothing showstopping.
+	if err = utils.SetToken(vaultClient, tokenID, data.Auth); err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot cache token for future runs: %v", err)
+	}
+
+	return nil
+}
+
+// ConfigureClientCertificate makes the clients created from config present the PEM encoded certificate and key
+func ConfigureClientCertificate(config *api.Config, certificate, key []byte) error {
+	clientCert, err := tls.X509KeyPair(certificate, key)
+	if err != nil {
+		return fmt.Errorf("could not load client certificate: %s", err)
+	}
+
+	transport, ok := config.HttpClient.Transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("unsupported HTTPClient transport type %T", config.HttpClient.Transport)
+	}
+
+	// Like the Vault client, ignore the CAs preferred by the server so any CA trusted by the cert auth method can be used
+	transport.TLSClientConfig.GetClientCertificate = func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+		return &clientCert, nil
+	}
+	return nil
+}
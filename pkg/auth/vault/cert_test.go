@@ -0,0 +1,41 @@
+package vault_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/auth/vault"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+func TestCertAuth(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	cert := vault.NewCertAuth("argocd", "")
+
+	err := cert.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	cachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, cert.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	err = cert.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	newCachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, cert.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	if bytes.Compare(cachedToken, newCachedToken) != 0 {
+		t.Fatalf("expected same token %s but got %s", cachedToken, newCachedToken)
+	}
+}
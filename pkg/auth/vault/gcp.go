@@ -0,0 +1,111 @@
+package vault
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/hashicorp/vault/api"
+	iamcredentials "google.golang.org/api/iamcredentials/v1"
+)
+
+const (
+	gcpMountPath = "auth/gcp"
+
+	// Vault rejects JWTs that expire more than 15 minutes after they are issued
+	gcpJWTExpiry = 10 * time.Minute
+)
+
+// GCPAuth is a struct for working with Vault that uses the Google Cloud service account of the plugin,
+// logging in with an iam type role
+type GCPAuth struct {
+	Role string
+
+	// Optional, will use the service account of the metadata server, e.g. from GKE Workload Identity, if left blank
+	ServiceAccount string
+
+	MountPath string
+	IAM       *iamcredentials.Service
+}
+
+// NewGCPAuth initializes a new GCPAuth signing its JWTs as serviceAccount through the IAM Credentials API
+func NewGCPAuth(role, serviceAccount, mountPath string, iam *iamcredentials.Service) *GCPAuth {
+	gcpAuth := &GCPAuth{
+		Role:           role,
+		ServiceAccount: serviceAccount,
+		MountPath:      gcpMountPath,
+		IAM:            iam,
+	}
+	if mountPath != "" {
+		gcpAuth.MountPath = mountPath
+	}
+
+	return gcpAuth
+}
+
+// Authenticate authenticates with Vault using a JWT signed by Google for the service account and returns a token
+func (a *GCPAuth) Authenticate(vaultClient *api.Client) error {
+	tokenID := utils.TokenCacheID(vaultClient, a.MountPath, a.Role)
+	err := utils.LoginWithCachedToken(vaultClient, tokenID)
+	if err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot retrieve cached token: %v. Generating a new one", err)
+	} else {
+		return nil
+	}
+
+	token, err := a.signJWT(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"role": a.Role,
+		"jwt":  token,
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authenticating with Vault role %s using JWT %s signed for %s", a.Role, token, a.ServiceAccount)
+	data, err := vaultClient.Logical().Write(fmt.Sprintf("%s/login", a.MountPath), payload)
+	if err != nil {
+		return err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authentication response: %v", data)
+
+	// If we cannot write the Vault token, we'll just have to login next time. Nothing showstopping.
+	if err = utils.SetToken(vaultClient, tokenID, data.Auth); err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot cache token for future runs: %v", err)
+	}
+
+	return nil
+}
+
+func (a *GCPAuth) signJWT(ctx context.Context) (string, error) {
+	if a.ServiceAccount == "" {
+		email, err := metadata.Email("default")
+		if err != nil {
+			return "", fmt.Errorf("could not find the service account from the metadata server: %s", err)
+		}
+		a.ServiceAccount = email
+	}
+
+	claims, err := json.Marshal(map[string]interface{}{
+		"sub": a.ServiceAccount,
+		"aud": fmt.Sprintf("vault/%s", a.Role),
+		"exp": time.Now().Add(gcpJWTExpiry).Unix(),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	name := fmt.Sprintf("projects/-/serviceAccounts/%s", a.ServiceAccount)
+	response, err := a.IAM.Projects.ServiceAccounts.SignJwt(name, &iamcredentials.SignJwtRequest{
+		Payload: string(claims),
+	}).Context(ctx).Do()
+	if err != nil {
+		return "", fmt.Errorf("could not sign JWT for %s: %s", a.ServiceAccount, err)
+	}
+	return response.SignedJwt, nil
+}
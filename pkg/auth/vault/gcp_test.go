@@ -0,0 +1,70 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/auth/vault"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"google.golang.org/api/iamcredentials/v1"
+	"google.golang.org/api/option"
+)
+
+func TestGCPAuth(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	var paths, claims []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request iamcredentials.SignJwtRequest
+		json.NewDecoder(r.Body).Decode(&request)
+		paths = append(paths, r.URL.Path)
+		claims = append(claims, request.Payload)
+		json.NewEncoder(w).Encode(iamcredentials.SignJwtResponse{KeyId: "key", SignedJwt: "signed-jwt"})
+	}))
+	defer server.Close()
+
+	iam, err := iamcredentials.NewService(context.Background(), option.WithEndpoint(server.URL), option.WithoutAuthentication())
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+	gcp := vault.NewGCPAuth("argocd", "argocd@project.iam.gserviceaccount.com", "", iam)
+
+	err = gcp.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	if len(paths) != 1 || paths[0] != "/v1/projects/-/serviceAccounts/argocd@project.iam.gserviceaccount.com:signJwt" {
+		t.Fatalf("expected 1 JWT signed for the service account but got %v", paths)
+	}
+	var payload map[string]interface{}
+	json.Unmarshal([]byte(claims[0]), &payload)
+	if payload["sub"] != "argocd@project.iam.gserviceaccount.com" || payload["aud"] != "vault/argocd" {
+		t.Fatalf("expected the JWT to be issued for vault/argocd but got %s", claims[0])
+	}
+
+	cachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, gcp.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	err = gcp.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	newCachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, gcp.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	if bytes.Compare(cachedToken, newCachedToken) != 0 {
+		t.Fatalf("expected same token %s but got %s", cachedToken, newCachedToken)
+	}
+}
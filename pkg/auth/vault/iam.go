@@ -0,0 +1,126 @@
+package vault
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	awsMountPath = "auth/aws"
+
+	// Vault verifies the signed request against the global STS endpoint unless configured otherwise
+	stsEndpoint = "https://sts.amazonaws.com/"
+	stsRegion   = "us-east-1"
+	stsBody     = "Action=GetCallerIdentity&Version=2011-06-15"
+
+	iamServerIDHeader = "X-Vault-AWS-IAM-Server-ID"
+)
+
+// IAMAuth is a struct for working with Vault that uses the AWS IAM identity of the plugin
+type IAMAuth struct {
+	// Optional, Vault uses the friendly name of the IAM principal if left blank
+	Role string
+
+	// Optional, must match the iam_server_id_header_value configured in Vault if any
+	ServerIDHeaderValue string
+
+	MountPath   string
+	Credentials aws.CredentialsProvider
+}
+
+// NewIAMAuth initializes a new IAMAuth signing its login requests with credentials
+func NewIAMAuth(role, serverIDHeaderValue, mountPath string, credentials aws.CredentialsProvider) *IAMAuth {
+	iamAuth := &IAMAuth{
+		Role:                role,
+		ServerIDHeaderValue: serverIDHeaderValue,
+		MountPath:           awsMountPath,
+		Credentials:         credentials,
+	}
+	if mountPath != "" {
+		iamAuth.MountPath = mountPath
+	}
+
+	return iamAuth
+}
+
+// Authenticate authenticates with Vault using a signed sts:GetCallerIdentity request and returns a token
+func (a *IAMAuth) Authenticate(vaultClient *api.Client) error {
+	tokenID := utils.TokenCacheID(vaultClient, a.MountPath, a.Role)
+	err := utils.LoginWithCachedToken(vaultClient, tokenID)
+	if err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot retrieve cached token: %v. Generating a new one", err)
+	} else {
+		return nil
+	}
+
+	payload, err := a.loginData(context.TODO())
+	if err != nil {
+		return err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authenticating with AWS IAM and role %s", a.Role)
+	data, err := vaultClient.Logical().Write(fmt.Sprintf("%s/login", a.MountPath), payload)
+	if err != nil {
+		return err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authentication response: %v", data)
+
+	// If we cannot write the Vault token, we'll just have to login next time. Nothing showstopping.
+	if err = utils.SetToken(vaultClient, tokenID, data.Auth); err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot cache token for future runs: %v", err)
+	}
+
+	return nil
+}
+
+// loginData signs a sts:GetCallerIdentity request, which Vault forwards to AWS to learn who we are
+func (a *IAMAuth) loginData(ctx context.Context) (map[string]interface{}, error) {
+	credentials, err := a.Credentials.Retrieve(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("could not retrieve AWS credentials: %s", err)
+	}
+
+	request, err := http.NewRequest(http.MethodPost, stsEndpoint, strings.NewReader(stsBody))
+	if err != nil {
+		return nil, err
+	}
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded; charset=utf-8")
+	if a.ServerIDHeaderValue != "" {
+		request.Header.Set(iamServerIDHeader, a.ServerIDHeaderValue)
+	}
+
+	payloadHash := sha256.Sum256([]byte(stsBody))
+	err = v4.NewSigner().SignHTTP(ctx, credentials, request, hex.EncodeToString(payloadHash[:]), "sts", stsRegion, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("could not sign sts:GetCallerIdentity request: %s", err)
+	}
+
+	headers, err := json.Marshal(request.Header)
+	if err != nil {
+		return nil, err
+	}
+
+	payload := map[string]interface{}{
+		"iam_http_request_method": request.Method,
+		"iam_request_url":         base64.StdEncoding.EncodeToString([]byte(request.URL.String())),
+		"iam_request_headers":     base64.StdEncoding.EncodeToString(headers),
+		"iam_request_body":        base64.StdEncoding.EncodeToString([]byte(stsBody)),
+	}
+	if a.Role != "" {
+		payload["role"] = a.Role
+	}
+	return payload, nil
+}
@@ -0,0 +1,63 @@
+package vault_test
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/auth/vault"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/aws/aws-sdk-go-v2/aws"
+)
+
+func TestIAMAuth(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "secret"}, nil
+	})
+	iam := vault.NewIAMAuth("argocd", "vault.example.com", "", credentials)
+
+	err := iam.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	cachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, iam.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	err = iam.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	newCachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, iam.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	if bytes.Compare(cachedToken, newCachedToken) != 0 {
+		t.Fatalf("expected same token %s but got %s", cachedToken, newCachedToken)
+	}
+}
+
+func TestIAMAuthNoCredentials(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	credentials := aws.CredentialsProviderFunc(func(ctx context.Context) (aws.Credentials, error) {
+		return aws.Credentials{}, fmt.Errorf("no EC2 IMDS role found")
+	})
+	iam := vault.NewIAMAuth("no-credentials", "", "", credentials)
+
+	err := iam.Authenticate(cluster.Cores[0].Client)
+	expected := "could not retrieve AWS credentials: no EC2 IMDS role found"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %s but got %v", expected, err)
+	}
+}
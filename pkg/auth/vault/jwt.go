@@ -0,0 +1,72 @@
+package vault
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/hashicorp/vault/api"
+)
+
+const (
+	jwtMountPath = "auth/jwt"
+)
+
+// JWTAuth is a struct for working with Vault that uses a JWT issued by an external identity provider,
+// such as a projected service account token
+type JWTAuth struct {
+	Role      string
+	TokenPath string
+	MountPath string
+}
+
+// NewJWTAuth initializes a new JWTAuth with role and the path of the token to log in with
+func NewJWTAuth(role, tokenPath, mountPath string) *JWTAuth {
+	jwtAuth := &JWTAuth{
+		Role:      role,
+		TokenPath: tokenPath,
+		MountPath: jwtMountPath,
+	}
+	if mountPath != "" {
+		jwtAuth.MountPath = mountPath
+	}
+
+	return jwtAuth
+}
+
+// Authenticate authenticates with Vault using the JWT and returns a token
+func (a *JWTAuth) Authenticate(vaultClient *api.Client) error {
+	tokenID := utils.TokenCacheID(vaultClient, a.MountPath, a.Role)
+	err := utils.LoginWithCachedToken(vaultClient, tokenID)
+	if err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot retrieve cached token: %v. Generating a new one", err)
+	} else {
+		return nil
+	}
+
+	token, err := os.ReadFile(a.TokenPath)
+	if err != nil {
+		return fmt.Errorf("could not read JWT: %s", err)
+	}
+
+	payload := map[string]interface{}{
+		"role": a.Role,
+		"jwt":  strings.TrimSpace(string(token)),
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authenticating with Vault role %s using JWT %s read from %s", a.Role, token, a.TokenPath)
+	data, err := vaultClient.Logical().Write(fmt.Sprintf("%s/login", a.MountPath), payload)
+	if err != nil {
+		return err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault authentication response: %v", data)
+
+	// If we cannot write the Vault token, we'll just have to login next time. Nothing showstopping.
+	if err = utils.SetToken(vaultClient, tokenID, data.Auth); err != nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot cache token for future runs: %v", err)
+	}
+
+	return nil
+}
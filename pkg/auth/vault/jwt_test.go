@@ -0,0 +1,61 @@
+package vault_test
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/auth/vault"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+func TestJWTAuth(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	tokenPath := filepath.Join(t.TempDir(), "token")
+	err := os.WriteFile(tokenPath, []byte("eyJhbGciOiJSUzI1NiJ9.e30.c2lnbmF0dXJl\n"), 0644)
+	if err != nil {
+		t.Fatalf("error writing token: %s", err)
+	}
+
+	jwt := vault.NewJWTAuth("argocd", tokenPath, "")
+
+	err = jwt.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	cachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, jwt.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	err = jwt.Authenticate(cluster.Cores[0].Client)
+	if err != nil {
+		t.Fatalf("expected no errors but got: %s", err)
+	}
+
+	newCachedToken, err := utils.ReadExistingToken(utils.TokenCacheID(cluster.Cores[0].Client, jwt.MountPath, "argocd"))
+	if err != nil {
+		t.Fatalf("expected cached vault token but got: %s", err)
+	}
+
+	if bytes.Compare(cachedToken, newCachedToken) != 0 {
+		t.Fatalf("expected same token %s but got %s", cachedToken, newCachedToken)
+	}
+}
+
+func TestJWTAuthMissingToken(t *testing.T) {
+	cluster := helpers.CreateTestAuthVault(t)
+	defer cluster.Cleanup()
+
+	jwt := vault.NewJWTAuth("missing-token", filepath.Join(t.TempDir(), "token"), "")
+
+	err := jwt.Authenticate(cluster.Cores[0].Client)
+	if err == nil {
+		t.Fatalf("expected an error reading the missing token")
+	}
+}
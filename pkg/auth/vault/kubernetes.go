@@ -39,7 +39,13 @@ func NewK8sAuth(role, mountPath, tokenPath string) *K8sAuth {
 
 // Authenticate authenticates with Vault via K8s and returns a token
 func (k *K8sAuth) Authenticate(vaultClient *api.Client) error {
-	err := utils.LoginWithCachedToken(vaultClient, "kubernetes")
+	kubeAuthPath := kubernetesMountPath
+	if k.MountPath != "" {
+		kubeAuthPath = k.MountPath
+	}
+
+	tokenID := utils.TokenCacheID(vaultClient, kubeAuthPath, k.Role)
+	err := utils.LoginWithCachedToken(vaultClient, tokenID)
 	if err != nil {
 		utils.VerboseToStdErr("Hashicorp Vault cannot retrieve cached token: %v. Generating a new one", err)
 	} else {
@@ -56,11 +62,6 @@ func (k *K8sAuth) Authenticate(vaultClient *api.Client) error {
 		"jwt":  token,
 	}
 
-	kubeAuthPath := kubernetesMountPath
-	if k.MountPath != "" {
-		kubeAuthPath = k.MountPath
-	}
-
 	utils.VerboseToStdErr("Hashicorp Vault authenticating with Vault role %s using Kubernetes service account token %s read from %s", k.Role, serviceAccountFile, token)
 	data, err := vaultClient.Logical().Write(fmt.Sprintf("%s/login", kubeAuthPath), payload)
 	if err != nil {
@@ -70,7 +71,7 @@ func (k *K8sAuth) Authenticate(vaultClient *api.Client) error {
 	utils.VerboseToStdErr("Hashicorp Vault authentication response: %v", data)
 
 	// If we cannot write the Vault token, we'll just have to login next time. Nothing showstopping.
-	err = utils.SetToken(vaultClient, "kubernetes", data.Auth.ClientToken)
+	err = utils.SetToken(vaultClient, tokenID, data.Auth)
 	if err != nil {
 		utils.VerboseToStdErr("Hashicorp Vault cannot cache token for future runs: %v", err)
 	}
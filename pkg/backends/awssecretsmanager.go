@@ -3,12 +3,15 @@ package backends
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"regexp"
 
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	smtypes "github.com/aws/aws-sdk-go-v2/service/secretsmanager/types"
 )
 
 const (
@@ -67,6 +70,10 @@ func (a *AWSSecretsManager) GetSecrets(path string, version string, annotations
 	utils.VerboseToStdErr("AWS Secrets Manager getting secret %s at version %s", path, version)
 	result, err := a.Client.GetSecretValue(context.TODO(), input, opts)
 	if err != nil {
+		var notFound *smtypes.ResourceNotFoundException
+		if errors.As(err, &notFound) {
+			return nil, &types.NotFoundError{Err: err}
+		}
 		return nil, err
 	}
 
@@ -85,7 +92,7 @@ func (a *AWSSecretsManager) GetSecrets(path string, version string, annotations
 		dat["SecretBinary"] = result.SecretBinary
 		return dat, nil
 	} else {
-		return nil, fmt.Errorf("Could not find secret %s", path)
+		return nil, &types.NotFoundError{Err: fmt.Errorf("Could not find secret %s", path)}
 	}
 
 	return dat, nil
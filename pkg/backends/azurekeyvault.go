@@ -2,10 +2,14 @@ package backends
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
 	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	"time"
 )
@@ -107,6 +111,10 @@ func (a *AzureKeyVault) GetIndividualSecret(kvpath, secret, version string, anno
 
 	data, err := client.GetSecret(ctx, secret, version, nil)
 	if err != nil {
+		var responseErr *azcore.ResponseError
+		if errors.As(err, &responseErr) && responseErr.StatusCode == http.StatusNotFound {
+			return nil, &types.NotFoundError{Err: err}
+		}
 		return nil, err
 	}
 
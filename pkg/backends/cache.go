@@ -0,0 +1,237 @@
+package backends
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+// lookupAnnotations are the annotations that can change what a Backend returns for the same path and version
+var lookupAnnotations = []string{
+	types.VaultKVVersionAnnotation,
+	types.VaultNamespaceAnnotation,
+	types.AVPBackendAnnotation,
+}
+
+// lookupKey identifies a GetSecrets (empty secret) or GetIndividualSecret call
+type lookupKey struct {
+	path        string
+	secret      string
+	version     string
+	annotations string
+}
+
+func newLookupKey(path, secret, version string, annotations map[string]string, selectsBackend bool) lookupKey {
+	var relevant []string
+	for _, name := range lookupAnnotations {
+		// Only a Registry selects a Backend, the Backends it selects from return the same secrets either way
+		if name == types.AVPBackendAnnotation && !selectsBackend {
+			continue
+		}
+		if value, ok := annotations[name]; ok {
+			relevant = append(relevant, name+"="+value)
+		}
+	}
+	sort.Strings(relevant)
+
+	return lookupKey{
+		path:        path,
+		secret:      secret,
+		version:     version,
+		annotations: strings.Join(relevant, ","),
+	}
+}
+
+type lookupResult struct {
+	secrets map[string]interface{}
+	value   interface{}
+	err     error
+}
+
+// cacheEntry holds the result of a lookup, which is only made by the first caller
+type cacheEntry struct {
+	once   sync.Once
+	result lookupResult
+}
+
+// Cache wraps a Backend and memoizes its lookups, so that every template of a run shares their results
+// It is safe for concurrent use: concurrent lookups of the same secret only reach the wrapped Backend once
+type Cache struct {
+	types.Backend
+
+	// Whether the wrapped Backend is a Registry, whose lookups depend on the `avp.kubernetes.io/backend` annotation
+	selectsBackend bool
+
+	entries     map[lookupKey]*cacheEntry
+	entriesLock sync.Mutex
+}
+
+// NewCacheBackend wraps backend with a Cache
+// The Backends of a Chain or Registry get a Cache of their own, as their individual secrets can only be shared per Backend
+func NewCacheBackend(backend types.Backend) *Cache {
+	backend = cacheInner(backend)
+	_, selectsBackend := backend.(*Registry)
+	return &Cache{
+		Backend:        backend,
+		selectsBackend: selectsBackend,
+		entries:        make(map[lookupKey]*cacheEntry),
+	}
+}
+
+// cacheInner returns a copy of a Chain or Registry whose Backends are wrapped with a Cache, and any other backend as is
+func cacheInner(backend types.Backend) types.Backend {
+	switch b := backend.(type) {
+	case *Chain:
+		inner := make([]types.Backend, len(b.Backends))
+		for i, chained := range b.Backends {
+			inner[i] = NewCacheBackend(chained)
+		}
+		return NewChainBackend(inner...)
+	case *Registry:
+		// The default Backend may also be registered under a name, both must share the same Cache
+		caches := make(map[types.Backend]types.Backend)
+		cached := func(inner types.Backend) types.Backend {
+			if inner == nil {
+				return nil
+			}
+			if _, ok := caches[inner]; !ok {
+				caches[inner] = NewCacheBackend(inner)
+			}
+			return caches[inner]
+		}
+
+		named := make(map[string]types.Backend, len(b.Named))
+		for name, inner := range b.Named {
+			named[name] = cached(inner)
+		}
+		return NewRegistryBackend(cached(b.Default), named)
+	default:
+		return backend
+	}
+}
+
+func (c *Cache) lookup(key lookupKey, fetch func() lookupResult) lookupResult {
+	c.entriesLock.Lock()
+	entry, ok := c.entries[key]
+	if !ok {
+		entry = &cacheEntry{}
+		c.entries[key] = entry
+	}
+	c.entriesLock.Unlock()
+
+	if ok {
+		utils.VerboseToStdErr("using cached lookup of path %s", key.path)
+	}
+	entry.once.Do(func() {
+		entry.result = fetch()
+	})
+	return entry.result
+}
+
+// GetSecrets returns the secrets at `path`, only retrieving them from the wrapped Backend once
+func (c *Cache) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	result := c.lookup(newLookupKey(path, "", version, annotations, c.selectsBackend), func() (r lookupResult) {
+		r.secrets, r.err = c.Backend.GetSecrets(path, version, annotations)
+		return r
+	})
+	return result.secrets, result.err
+}
+
+// GetIndividualSecret returns the secret from `path`, only retrieving it from the wrapped Backend once
+// For Backends whose individual secrets are a key of GetSecrets, all the keys of a path share a single lookup
+func (c *Cache) GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error) {
+	if derivesIndividualSecret(c.Backend, secret) {
+		data, err := c.GetSecrets(path, version, annotations)
+		if err != nil {
+			return nil, err
+		}
+		return data[secret], nil
+	}
+
+	result := c.lookup(newLookupKey(path, secret, version, annotations, c.selectsBackend), func() (r lookupResult) {
+		r.value, r.err = c.Backend.GetIndividualSecret(path, secret, version, annotations)
+		return r
+	})
+	return result.value, result.err
+}
+
+// Prefetch performs every lookup in refs ahead of time, using up to `workers` concurrent calls to the wrapped Backend
+// Errors are cached like any other result and returned by the matching GetSecrets/GetIndividualSecret call,
+// so that they are reported against the manifest that needs the secret
+func (c *Cache) Prefetch(refs []types.SecretRef, workers int) {
+	if workers < 1 {
+		workers = 1
+	}
+	if !concurrencySafe(c.Backend) {
+		utils.VerboseToStdErr("backend %T does not support concurrent lookups, prefetching sequentially", c.Backend)
+		workers = 1
+	}
+
+	utils.VerboseToStdErr("prefetching %d secret references with %d workers", len(refs), workers)
+
+	var wg sync.WaitGroup
+	jobs := make(chan types.SecretRef)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for ref := range jobs {
+				if ref.Key == "" {
+					c.GetSecrets(ref.Path, ref.Version, ref.Annotations)
+				} else {
+					c.GetIndividualSecret(ref.Path, ref.Key, ref.Version, ref.Annotations)
+				}
+			}
+		}()
+	}
+
+	for _, ref := range refs {
+		jobs <- ref
+	}
+	close(jobs)
+	wg.Wait()
+}
+
+// derivesIndividualSecret reports whether backend's GetIndividualSecret returns the secret as a key of its GetSecrets result
+func derivesIndividualSecret(backend types.Backend, secret string) bool {
+	switch backend.(type) {
+	case *Vault:
+		// The metadata of KV-V2 secrets is not part of their data
+		return !strings.HasPrefix(secret, types.VaultMetadataPrefix)
+	case *AWSSecretsManager, *GCPSecretManager, *KeeperSecretsManager, *OnePasswordConnect,
+		*KubernetesSecret, *LocalSecretManager, *DelineaSecretServer:
+		return true
+	default:
+		return false
+	}
+}
+
+// concurrencySafe reports whether backend can be called from several goroutines at once
+func concurrencySafe(backend types.Backend) bool {
+	switch b := backend.(type) {
+	case *IBMSecretsManager:
+		// IBMSecretsManager keeps unsynchronized caches and already parallelizes its own API calls
+		return false
+	case *Cache:
+		return concurrencySafe(b.Backend)
+	case *Chain:
+		for _, inner := range b.Backends {
+			if !concurrencySafe(inner) {
+				return false
+			}
+		}
+	case *Registry:
+		if b.Default != nil && !concurrencySafe(b.Default) {
+			return false
+		}
+		for _, inner := range b.Named {
+			if !concurrencySafe(inner) {
+				return false
+			}
+		}
+	}
+	return true
+}
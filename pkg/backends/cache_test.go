@@ -0,0 +1,262 @@
+package backends_test
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/backends"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	yaml "sigs.k8s.io/yaml"
+)
+
+// countingBackend records how many lookups reach it
+type countingBackend struct {
+	lock    sync.Mutex
+	calls   map[string]int
+	secrets map[string]map[string]interface{}
+}
+
+func (c *countingBackend) Login() error {
+	return nil
+}
+
+func (c *countingBackend) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.calls[path]++
+
+	data, ok := c.secrets[path]
+	if !ok {
+		return nil, &types.NotFoundError{Err: fmt.Errorf("Could not find secrets at path %s", path)}
+	}
+	return data, nil
+}
+
+func (c *countingBackend) GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error) {
+	data, err := c.GetSecrets(path, version, annotations)
+	if err != nil {
+		return nil, err
+	}
+	return data[secret], nil
+}
+
+func (c *countingBackend) decrypt(path string, format string) ([]byte, error) {
+	data, err := c.GetSecrets(path, "", nil)
+	if err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(data)
+}
+
+func newCountingBackend() *countingBackend {
+	return &countingBackend{
+		calls: make(map[string]int),
+		secrets: map[string]map[string]interface{}{
+			"secret/app": {
+				"user":     "admin",
+				"password": "hunter2",
+			},
+		},
+	}
+}
+
+func TestCacheGetSecrets(t *testing.T) {
+	inner := newCountingBackend()
+	cache := backends.NewCacheBackend(inner)
+
+	t.Run("will only look up a path once", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			data, err := cache.GetSecrets("secret/app", "", nil)
+			if err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if !reflect.DeepEqual(data, inner.secrets["secret/app"]) {
+				t.Errorf("expected: %s, got: %s.", inner.secrets["secret/app"], data)
+			}
+		}
+
+		if inner.calls["secret/app"] != 1 {
+			t.Fatalf("expected 1 lookup, got %d", inner.calls["secret/app"])
+		}
+	})
+
+	t.Run("will cache errors", func(t *testing.T) {
+		for i := 0; i < 2; i++ {
+			_, err := cache.GetSecrets("secret/missing", "", nil)
+			expected := "Could not find secrets at path secret/missing"
+			if err == nil || err.Error() != expected {
+				t.Fatalf("expected error %s but got: %v", expected, err)
+			}
+		}
+
+		if inner.calls["secret/missing"] != 1 {
+			t.Fatalf("expected 1 lookup, got %d", inner.calls["secret/missing"])
+		}
+	})
+
+	t.Run("will key lookups on version and relevant annotations", func(t *testing.T) {
+		cache.GetSecrets("secret/app", "2", nil)
+		cache.GetSecrets("secret/app", "", map[string]string{types.VaultKVVersionAnnotation: "1"})
+		cache.GetSecrets("secret/app", "", map[string]string{types.AVPIgnoreAnnotation: "false"})
+
+		if inner.calls["secret/app"] != 3 {
+			t.Fatalf("expected 3 lookups, got %d", inner.calls["secret/app"])
+		}
+	})
+
+	t.Run("will share lookups between goroutines", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				cache.GetSecrets("secret/app", "3", nil)
+			}()
+		}
+		wg.Wait()
+
+		if inner.calls["secret/app"] != 4 {
+			t.Fatalf("expected 4 lookups, got %d", inner.calls["secret/app"])
+		}
+	})
+}
+
+func TestCacheGetIndividualSecret(t *testing.T) {
+	t.Run("will look up each key of an opaque backend once", func(t *testing.T) {
+		inner := newCountingBackend()
+		cache := backends.NewCacheBackend(inner)
+
+		for _, key := range []string{"user", "password", "user"} {
+			value, err := cache.GetIndividualSecret("secret/app", key, "", nil)
+			if err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if value != inner.secrets["secret/app"][key] {
+				t.Errorf("expected: %s, got: %s.", inner.secrets["secret/app"][key], value)
+			}
+		}
+
+		if inner.calls["secret/app"] != 2 {
+			t.Fatalf("expected 2 lookups, got %d", inner.calls["secret/app"])
+		}
+	})
+
+	t.Run("will share a single lookup between the keys of a path", func(t *testing.T) {
+		inner := newCountingBackend()
+		cache := backends.NewCacheBackend(backends.NewLocalSecretManagerBackend(inner.decrypt))
+
+		cache.GetSecrets("secret/app", "", nil)
+		for _, key := range []string{"user", "password"} {
+			value, err := cache.GetIndividualSecret("secret/app", key, "", nil)
+			if err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if value != inner.secrets["secret/app"][key] {
+				t.Errorf("expected: %s, got: %s.", inner.secrets["secret/app"][key], value)
+			}
+		}
+
+		if inner.calls["secret/app"] != 1 {
+			t.Fatalf("expected 1 lookup, got %d", inner.calls["secret/app"])
+		}
+	})
+}
+
+func TestCacheWrappedBackends(t *testing.T) {
+	t.Run("will share a single lookup between the keys of a path of each chained backend", func(t *testing.T) {
+		primary := newCountingBackend()
+		secondary := newCountingBackend()
+		secondary.secrets["secret/app"] = map[string]interface{}{
+			"token": "abc",
+		}
+		chain := backends.NewChainBackend(
+			backends.NewLocalSecretManagerBackend(primary.decrypt),
+			backends.NewLocalSecretManagerBackend(secondary.decrypt),
+		)
+		cache := backends.NewCacheBackend(chain)
+
+		expected := map[string]interface{}{
+			"user":     "admin",
+			"password": "hunter2",
+			"token":    "abc",
+		}
+		for _, key := range []string{"user", "password", "token", "user"} {
+			value, err := cache.GetIndividualSecret("secret/app", key, "", nil)
+			if err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if value != expected[key] {
+				t.Errorf("expected: %s, got: %s.", expected[key], value)
+			}
+		}
+
+		if primary.calls["secret/app"] != 1 || secondary.calls["secret/app"] != 1 {
+			t.Fatalf("expected 1 lookup of each backend, got %d and %d", primary.calls["secret/app"], secondary.calls["secret/app"])
+		}
+	})
+
+	t.Run("will share a single lookup between the keys of a path of each named backend", func(t *testing.T) {
+		corp := newCountingBackend()
+		cloud := newCountingBackend()
+		corpBackend := backends.NewLocalSecretManagerBackend(corp.decrypt)
+		registry := backends.NewRegistryBackend(corpBackend, map[string]types.Backend{
+			"corp":  corpBackend,
+			"cloud": backends.NewLocalSecretManagerBackend(cloud.decrypt),
+		})
+		cache := backends.NewCacheBackend(registry)
+
+		for _, annotations := range []map[string]string{nil, {types.AVPBackendAnnotation: "corp"}, {types.AVPBackendAnnotation: "cloud"}} {
+			for _, key := range []string{"user", "password"} {
+				value, err := cache.GetIndividualSecret("secret/app", key, "", annotations)
+				if err != nil {
+					t.Fatalf("expected 0 errors but got: %s", err)
+				}
+				if value != corp.secrets["secret/app"][key] {
+					t.Errorf("expected: %s, got: %s.", corp.secrets["secret/app"][key], value)
+				}
+			}
+		}
+
+		// The corp backend is also the default one
+		if corp.calls["secret/app"] != 1 || cloud.calls["secret/app"] != 1 {
+			t.Fatalf("expected 1 lookup of each backend, got %d and %d", corp.calls["secret/app"], cloud.calls["secret/app"])
+		}
+	})
+}
+
+func TestCachePrefetch(t *testing.T) {
+	inner := newCountingBackend()
+	cache := backends.NewCacheBackend(inner)
+
+	refs := []types.SecretRef{
+		{Path: "secret/app"},
+		{Path: "secret/app", Key: "user"},
+		{Path: "secret/app", Key: "user"},
+		{Path: "secret/app", Key: "password"},
+		{Path: "secret/missing", Key: "password"},
+	}
+	cache.Prefetch(refs, 4)
+
+	if inner.calls["secret/app"] != 3 {
+		t.Fatalf("expected each unique lookup to be made once, got %d calls", inner.calls["secret/app"])
+	}
+
+	value, err := cache.GetIndividualSecret("secret/app", "password", "", nil)
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	if value != "hunter2" {
+		t.Errorf("expected: hunter2, got: %s.", value)
+	}
+
+	_, err = cache.GetIndividualSecret("secret/missing", "password", "", nil)
+	if err == nil {
+		t.Fatalf("expected the prefetched error to be returned")
+	}
+
+	if inner.calls["secret/app"] != 3 || inner.calls["secret/missing"] != 1 {
+		t.Fatalf("expected no further lookups, got %v", inner.calls)
+	}
+}
@@ -0,0 +1,87 @@
+package backends
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+// Chain is a struct for working with several Backends in order of preference
+type Chain struct {
+	Backends []types.Backend
+}
+
+// NewChainBackend initializes a new Chain trying the given Backends in order
+func NewChainBackend(backends ...types.Backend) *Chain {
+	return &Chain{
+		Backends: backends,
+	}
+}
+
+// Login authenticates with every Backend of the chain
+func (c *Chain) Login() error {
+	for _, backend := range c.Backends {
+		err := backend.Login()
+		if err != nil {
+			return fmt.Errorf("%T: %s", backend, err)
+		}
+	}
+	return nil
+}
+
+// GetSecrets gets secrets from the first Backend that has secrets at `path`
+// The next Backend is only asked when a Backend has no secrets at `path`, any other error is returned as is
+func (c *Chain) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	var errs []string
+	for _, backend := range c.Backends {
+		data, err := backend.GetSecrets(path, version, annotations)
+		if err != nil {
+			if !types.IsNotFound(err) {
+				return nil, fmt.Errorf("%T: %w", backend, err)
+			}
+			utils.VerboseToStdErr("%T has no secrets at path %s, trying the next backend: %s", backend, path, err)
+			errs = append(errs, fmt.Sprintf("%T: %s", backend, err))
+			continue
+		}
+		if len(data) == 0 {
+			utils.VerboseToStdErr("%T has no secrets at path %s, trying the next backend", backend, path)
+			continue
+		}
+		return data, nil
+	}
+
+	// Only fail if no Backend has the path, otherwise the secrets are just missing
+	if len(errs) == len(c.Backends) {
+		return nil, &types.NotFoundError{Err: fmt.Errorf("no backend has secrets at path %s:\n%s", path, strings.Join(errs, "\n"))}
+	}
+	return map[string]interface{}{}, nil
+}
+
+// GetIndividualSecret gets the secret from the first Backend that has it at `path`
+// The next Backend is only asked when a Backend does not have the secret, any other error is returned as is
+func (c *Chain) GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error) {
+	var errs []string
+	for _, backend := range c.Backends {
+		value, err := backend.GetIndividualSecret(path, secret, version, annotations)
+		if err != nil {
+			if !types.IsNotFound(err) {
+				return nil, fmt.Errorf("%T: %w", backend, err)
+			}
+			utils.VerboseToStdErr("%T has no secret %s at path %s, trying the next backend: %s", backend, secret, path, err)
+			errs = append(errs, fmt.Sprintf("%T: %s", backend, err))
+			continue
+		}
+		if value == nil {
+			utils.VerboseToStdErr("%T has no secret %s at path %s, trying the next backend", backend, secret, path)
+			continue
+		}
+		return value, nil
+	}
+
+	if len(errs) == len(c.Backends) {
+		return nil, &types.NotFoundError{Err: fmt.Errorf("no backend has secret %s at path %s:\n%s", secret, path, strings.Join(errs, "\n"))}
+	}
+	return nil, nil
+}
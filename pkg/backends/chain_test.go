@@ -0,0 +1,141 @@
+package backends_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/backends"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+)
+
+// failingBackend fails every lookup, like a Backend that cannot be reached
+type failingBackend struct {
+	countingBackend
+}
+
+func (f *failingBackend) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func (f *failingBackend) GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error) {
+	return nil, fmt.Errorf("connection refused")
+}
+
+func TestChainGetSecrets(t *testing.T) {
+	primary := newCountingBackend()
+	secondary := newCountingBackend()
+	secondary.secrets = map[string]map[string]interface{}{
+		"secret/app": {
+			"user": "migrated",
+		},
+		"secret/other": {
+			"token": "abc",
+		},
+	}
+	chain := backends.NewChainBackend(primary, secondary)
+
+	t.Run("will use the first backend that has the path", func(t *testing.T) {
+		data, err := chain.GetSecrets("secret/app", "", nil)
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if !reflect.DeepEqual(data, primary.secrets["secret/app"]) {
+			t.Errorf("expected: %s, got: %s.", primary.secrets["secret/app"], data)
+		}
+		if secondary.calls["secret/app"] != 0 {
+			t.Errorf("expected the second backend not to be called")
+		}
+	})
+
+	t.Run("will fall back to the next backend", func(t *testing.T) {
+		data, err := chain.GetSecrets("secret/other", "", nil)
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if !reflect.DeepEqual(data, secondary.secrets["secret/other"]) {
+			t.Errorf("expected: %s, got: %s.", secondary.secrets["secret/other"], data)
+		}
+	})
+
+	t.Run("will not fall back when a backend fails", func(t *testing.T) {
+		chain := backends.NewChainBackend(&failingBackend{}, secondary)
+
+		_, err := chain.GetSecrets("secret/other", "", nil)
+		expected := "*backends_test.failingBackend: connection refused"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+		if secondary.calls["secret/other"] != 1 {
+			t.Errorf("expected the next backend not to be called")
+		}
+	})
+
+	t.Run("will report the errors of every backend", func(t *testing.T) {
+		_, err := chain.GetSecrets("secret/missing", "", nil)
+		expected := "no backend has secrets at path secret/missing:\n*backends_test.countingBackend: Could not find secrets at path secret/missing\n*backends_test.countingBackend: Could not find secrets at path secret/missing"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+}
+
+func TestChainGetIndividualSecret(t *testing.T) {
+	primary := newCountingBackend()
+	secondary := newCountingBackend()
+	secondary.secrets = map[string]map[string]interface{}{
+		"secret/app": {
+			"token": "abc",
+		},
+	}
+
+	t.Run("will fall back to the next backend for missing keys", func(t *testing.T) {
+		chain := backends.NewChainBackend(primary, secondary)
+
+		value, err := chain.GetIndividualSecret("secret/app", "token", "", nil)
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value != "abc" {
+			t.Errorf("expected: abc, got: %s.", value)
+		}
+	})
+
+	t.Run("will not fall back when a backend fails", func(t *testing.T) {
+		chain := backends.NewChainBackend(&failingBackend{}, primary)
+
+		_, err := chain.GetIndividualSecret("secret/app", "password", "", nil)
+		expected := "*backends_test.failingBackend: connection refused"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+		if types.IsNotFound(err) {
+			t.Errorf("expected the failure not to be reported as a missing secret")
+		}
+	})
+
+	t.Run("will report missing keys as missing when a backend has the path", func(t *testing.T) {
+		chain := backends.NewChainBackend(primary, secondary)
+
+		value, err := chain.GetIndividualSecret("secret/app", "nope", "", nil)
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value != nil {
+			t.Errorf("expected: nil, got: %s.", value)
+		}
+	})
+
+	t.Run("will fail when no backend has the path", func(t *testing.T) {
+		chain := backends.NewChainBackend(primary, secondary)
+
+		_, err := chain.GetIndividualSecret("secret/missing", "password", "", nil)
+		expected := "no backend has secret password at path secret/missing:\n*backends_test.countingBackend: Could not find secrets at path secret/missing\n*backends_test.countingBackend: Could not find secrets at path secret/missing"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+		if !types.IsNotFound(err) {
+			t.Errorf("expected the error to be reported as a missing secret")
+		}
+	})
+}
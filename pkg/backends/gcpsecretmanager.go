@@ -9,6 +9,8 @@ import (
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	"github.com/googleapis/gax-go/v2"
 	secretmanagerpb "google.golang.org/genproto/googleapis/cloud/secretmanager/v1"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 var GCPPath, _ = regexp.Compile(`projects/(?P<projectid>.+)/secrets/(?P<secretid>.+)`)
@@ -54,6 +56,9 @@ func (a *GCPSecretManager) GetSecrets(path string, version string, annotations m
 	utils.VerboseToStdErr("GCP Secret Manager accessing secret at path %s at version  %v", path, version)
 	result, err := a.Client.AccessSecretVersion(a.Context, req)
 	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return nil, &types.NotFoundError{Err: fmt.Errorf("Could not find secret: %v", err)}
+		}
 		return nil, fmt.Errorf("Could not find secret: %v", err)
 	}
 
@@ -3,6 +3,7 @@ package backends
 import (
 	"fmt"
 
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	ksm "github.com/keeper-security/secrets-manager-go/core"
 )
@@ -107,7 +108,7 @@ func (a *KeeperSecretsManager) GetSecrets(path string, version string, annotatio
 	utils.VerboseToStdErr("Keeper Secrets Manager getting path %s", path)
 
 	if len(records) == 0 {
-		return nil, fmt.Errorf("no secrets could be found with the given path: %s", path)
+		return nil, &types.NotFoundError{Err: fmt.Errorf("no secrets could be found with the given path: %s", path)}
 	}
 
 	if len(records) > 1 {
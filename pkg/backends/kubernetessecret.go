@@ -2,8 +2,10 @@ package backends
 
 import (
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/kube"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	"github.com/pkg/errors"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 type kubeSecretsClient interface {
@@ -35,6 +37,9 @@ func (k *KubernetesSecret) GetSecrets(path string, version string, annotations m
 	utils.VerboseToStdErr("K8s Secret getting secret: %s", path)
 	data, err := k.client.ReadSecretData(path)
 	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return nil, &types.NotFoundError{Err: err}
+		}
 		return nil, err
 	}
 
@@ -0,0 +1,88 @@
+package backends
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+)
+
+// Registry is a struct for working with several named Backends, selected per lookup
+// with the `avp.kubernetes.io/backend` annotation
+type Registry struct {
+	Default types.Backend
+	Named   map[string]types.Backend
+}
+
+// NewRegistryBackend initializes a new Registry, using defaultBackend for lookups that select no Backend
+func NewRegistryBackend(defaultBackend types.Backend, named map[string]types.Backend) *Registry {
+	return &Registry{
+		Default: defaultBackend,
+		Named:   named,
+	}
+}
+
+// Login authenticates with every Backend of the registry
+func (r *Registry) Login() error {
+	if r.Default != nil {
+		err := r.Default.Login()
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range r.names() {
+		// The default Backend may also be registered under a name
+		if r.Named[name] == r.Default {
+			continue
+		}
+		err := r.Named[name].Login()
+		if err != nil {
+			return fmt.Errorf("backend %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+// GetSecrets gets secrets at `path` from the Backend selected by `annotations`
+func (r *Registry) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	backend, err := r.selected(annotations)
+	if err != nil {
+		return nil, err
+	}
+	return backend.GetSecrets(path, version, annotations)
+}
+
+// GetIndividualSecret gets the secret at `path` from the Backend selected by `annotations`
+func (r *Registry) GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error) {
+	backend, err := r.selected(annotations)
+	if err != nil {
+		return nil, err
+	}
+	return backend.GetIndividualSecret(path, secret, version, annotations)
+}
+
+func (r *Registry) selected(annotations map[string]string) (types.Backend, error) {
+	name, ok := annotations[types.AVPBackendAnnotation]
+	if !ok || name == "" {
+		if r.Default == nil {
+			return nil, fmt.Errorf("no default backend is configured, select one of %v with %s or a <name:path:...> placeholder", r.names(), types.AVPBackendAnnotation)
+		}
+		return r.Default, nil
+	}
+
+	backend, ok := r.Named[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown backend %s, configured backends are %v", name, r.names())
+	}
+	return backend, nil
+}
+
+func (r *Registry) names() []string {
+	var names []string
+	for name := range r.Named {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,71 @@
+package backends_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/backends"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+)
+
+func TestRegistryGetSecrets(t *testing.T) {
+	corp := newCountingBackend()
+	cloud := newCountingBackend()
+	cloud.secrets = map[string]map[string]interface{}{
+		"secret/app": {
+			"token": "abc",
+		},
+	}
+	registry := backends.NewRegistryBackend(corp, map[string]types.Backend{
+		"corp":  corp,
+		"cloud": cloud,
+	})
+
+	t.Run("will use the default backend without a selection", func(t *testing.T) {
+		data, err := registry.GetSecrets("secret/app", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if !reflect.DeepEqual(data, corp.secrets["secret/app"]) {
+			t.Errorf("expected: %s, got: %s.", corp.secrets["secret/app"], data)
+		}
+	})
+
+	t.Run("will use the selected backend", func(t *testing.T) {
+		value, err := registry.GetIndividualSecret("secret/app", "token", "", map[string]string{
+			types.AVPBackendAnnotation: "cloud",
+		})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value != "abc" {
+			t.Errorf("expected: abc, got: %s.", value)
+		}
+	})
+
+	t.Run("will fail for unknown backends", func(t *testing.T) {
+		_, err := registry.GetSecrets("secret/app", "", map[string]string{
+			types.AVPBackendAnnotation: "legacy",
+		})
+		expected := "unknown backend legacy, configured backends are [cloud corp]"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+}
+
+func TestRegistryWithoutDefault(t *testing.T) {
+	registry := backends.NewRegistryBackend(nil, map[string]types.Backend{
+		"corp": newCountingBackend(),
+	})
+
+	if err := registry.Login(); err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+
+	_, err := registry.GetIndividualSecret("secret/app", "user", "", nil)
+	expected := "no default backend is configured, select one of [corp] with avp.kubernetes.io/backend or a <name:path:...> placeholder"
+	if err == nil || err.Error() != expected {
+		t.Fatalf("expected error %s but got %v", expected, err)
+	}
+}
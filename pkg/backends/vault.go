@@ -1,8 +1,12 @@
 package backends
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/url"
+	"strings"
+	"sync"
 
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
@@ -13,7 +17,23 @@ import (
 type Vault struct {
 	types.AuthType
 	VaultClient *api.Client
-	KvVersion   string
+
+	// Optional, the KV version of every path is detected from its mount if left blank
+	KvVersion string
+
+	// Whether paths with request parameters are written to, as set by AVP_VAULT_ALLOW_WRITES
+	AllowWrites bool
+
+	lock   sync.Mutex
+	mounts []vaultMount
+}
+
+// vaultMount is a secrets engine mount, as detected with sys/internal/ui/mounts
+type vaultMount struct {
+	namespace string
+	path      string
+	engine    string
+	kvVersion string
 }
 
 // NewVaultBackend initializes a new Vault Backend
@@ -38,6 +58,12 @@ func (v *Vault) Login() error {
 
 // GetSecrets gets secrets from vault and returns the formatted data
 func (v *Vault) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	data, _, err := v.getSecrets(path, version, annotations)
+	return data, err
+}
+
+// getSecrets returns the data of the secret at path, and the metadata of its version for KV-V2 secrets
+func (v *Vault) getSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, map[string]interface{}, error) {
 	var secret *api.Secret
 	var err error
 
@@ -46,58 +72,259 @@ func (v *Vault) GetSecrets(path string, version string, annotations map[string]s
 		kvVersion = kv
 	}
 
+	var client = v.client(annotations)
+
+	// Request parameters, e.g. pki/issue/web?common_name=example.com, are written to the path of other secrets engines
+	if path, query, ok := strings.Cut(path, "?"); ok {
+		// Writing can overwrite secrets, so it must be allowed by the operator rather than by a manifest
+		if !v.AllowWrites {
+			return nil, nil, fmt.Errorf("Vault path %s has request parameters, which are only written to Vault when %s is true", path, types.EnvAvpVaultAllowWrites)
+		}
+		if mount, ok := v.lookupMount(client, path); ok && mount.engine == "kv" {
+			return nil, nil, fmt.Errorf("Vault path %s has request parameters, which cannot be written to the KV mount %s", path, mount.path)
+		}
+
+		parameters, err := url.ParseQuery(query)
+		if err != nil {
+			return nil, nil, fmt.Errorf("Invalid request parameters in Vault path %s: %s", path, err)
+		}
+
+		data := make(map[string]interface{}, len(parameters))
+		for name, values := range parameters {
+			if len(values) == 1 {
+				data[name] = values[0]
+			} else {
+				data[name] = values
+			}
+		}
+
+		utils.VerboseToStdErr("Hashicorp Vault writing to path %s with parameters %v", path, data)
+		secret, err := client.Logical().Write(path, data)
+		if err != nil {
+			return nil, nil, err
+		}
+		if secret == nil {
+			return nil, nil, &types.NotFoundError{Err: fmt.Errorf("Could not find secrets at path %s", path)}
+		}
+		return dynamicSecretData(path, secret), nil, nil
+	}
+
+	if kvVersion == "" {
+		kvVersion, path = v.detectKvVersion(client, path)
+	}
+
 	// Vault KV-V1 doesn't support versioning so we only honor `version` if KV-V2 is used
 	if version != "" && kvVersion == "2" {
 		utils.VerboseToStdErr("Hashicorp Vault getting kv pairs from KV-V2 path %s at version %s", path, version)
-		secret, err = v.VaultClient.Logical().ReadWithData(path, map[string][]string{
+		secret, err = client.Logical().ReadWithData(path, map[string][]string{
 			"version": {version},
 		})
 	} else {
 		utils.VerboseToStdErr("Hashicorp Vault getting kv pairs from KV-V1 path %s", path)
-		secret, err = v.VaultClient.Logical().Read(path)
+		secret, err = client.Logical().Read(path)
 	}
 
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
 
 	utils.VerboseToStdErr("Hashicorp Vault get kv pairs response: %v", secret)
 
+	if secret != nil && secret.LeaseID != "" {
+		// Only the credentials of dynamic secrets engines are leased, they are never KV-shaped
+		return dynamicSecretData(path, secret), nil, nil
+	}
+
 	if secret == nil {
 		// Do not mention `version` in error message when it's not honored (KV-V1)
 		if version == "" || kvVersion == "1" {
-			return nil, fmt.Errorf("Could not find secrets at path %s", path)
+			return nil, nil, &types.NotFoundError{Err: fmt.Errorf("Could not find secrets at path %s", path)}
 		}
-		return nil, fmt.Errorf("Could not find secrets at path %s with version %s", path, version)
+		return nil, nil, &types.NotFoundError{Err: fmt.Errorf("Could not find secrets at path %s with version %s", path, version)}
 	}
 
 	if kvVersion == "2" {
 		if _, ok := secret.Data["data"]; ok {
 			if secret.Data["data"] != nil {
-				return secret.Data["data"].(map[string]interface{}), nil
+				return secret.Data["data"].(map[string]interface{}), kvV2Metadata(secret), nil
 			}
-			return nil, fmt.Errorf("The secret version %s for Vault path %s is nil - is this version of the secret deleted?", version, path)
+			return nil, nil, &types.NotFoundError{Err: fmt.Errorf("The secret version %s for Vault path %s is nil - is this version of the secret deleted?", version, path)}
 		}
 		if len(secret.Data) == 0 {
-			return nil, fmt.Errorf("The Vault path: %s is empty - did you forget to include /data/ in the Vault path for kv-v2?", path)
+			return nil, nil, fmt.Errorf("The Vault path: %s is empty - did you forget to include /data/ in the Vault path for kv-v2?", path)
 		}
-		return nil, errors.New("Could not get data from Vault, check that kv-v2 is the correct engine")
+		return nil, nil, errors.New("Could not get data from Vault, check that kv-v2 is the correct engine")
 	}
 
 	if kvVersion == "1" {
-		return secret.Data, nil
+		return secret.Data, nil, nil
 	}
 
-	return nil, errors.New("Unsupported kvVersion specified")
+	return nil, nil, errors.New("Unsupported kvVersion specified")
 }
 
 // GetIndividualSecret will get the specific secret (placeholder) from the SM backend
 // For Vault, we only support placeholders replaced from the k/v pairs of a secret which cannot be individually addressed
-// So, we use GetSecrets and extract the specific placeholder we want
+// So, we use GetSecrets and extract the specific placeholder we want, or the metadata of KV-V2 secrets for `@metadata.` keys
 func (v *Vault) GetIndividualSecret(kvpath, secret, version string, annotations map[string]string) (interface{}, error) {
-	data, err := v.GetSecrets(kvpath, version, annotations)
+	data, versionMetadata, err := v.getSecrets(kvpath, version, annotations)
 	if err != nil {
 		return nil, err
 	}
-	return data[secret], nil
+
+	// Only KV-V2 secrets have metadata
+	name, ok := strings.CutPrefix(secret, types.VaultMetadataPrefix)
+	if !ok || versionMetadata == nil {
+		return data[secret], nil
+	}
+	if value, ok := versionMetadata[name]; ok {
+		return metadataValue(value)
+	}
+
+	// The rest of the KV-V2 metadata, like updated_time, is only read from the metadata endpoint when asked for
+	client := v.client(annotations)
+	metadataPath, err := v.metadataPath(client.Namespace(), kvpath)
+	if err != nil {
+		return nil, err
+	}
+
+	utils.VerboseToStdErr("Hashicorp Vault getting metadata from KV-V2 path %s", metadataPath)
+	metadata, err := client.Logical().Read(metadataPath)
+	if err != nil {
+		return nil, err
+	}
+	if metadata == nil {
+		return nil, &types.NotFoundError{Err: fmt.Errorf("Could not find metadata at path %s", metadataPath)}
+	}
+	return metadataValue(metadata.Data[name])
+}
+
+func (v *Vault) client(annotations map[string]string) *api.Client {
+	if namespace, ok := annotations[types.VaultNamespaceAnnotation]; ok {
+		utils.VerboseToStdErr("Hashicorp Vault using namespace %s", namespace)
+		return v.VaultClient.WithNamespace(namespace)
+	}
+	return v.VaultClient
+}
+
+// kvV2Metadata returns the metadata of the version of a KV-V2 secret, with its custom metadata under `custom_metadata.`
+func kvV2Metadata(secret *api.Secret) map[string]interface{} {
+	data := make(map[string]interface{})
+	metadata, _ := secret.Data["metadata"].(map[string]interface{})
+	for k, v := range metadata {
+		if k == "custom_metadata" {
+			custom, _ := v.(map[string]interface{})
+			for ck, cv := range custom {
+				data["custom_metadata."+ck] = cv
+			}
+			continue
+		}
+		data[k] = v
+	}
+	return data
+}
+
+// metadataValue returns a KV-V2 metadata value as a string, like the values of KV secrets, and JSON for anything but strings
+func metadataValue(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		return string(data), nil
+	}
+}
+
+// metadataPath returns the metadata endpoint of the KV-V2 secret at path
+func (v *Vault) metadataPath(namespace, path string) (string, error) {
+	if mount, ok := v.findMount(namespace, path); ok {
+		return mount.path + "metadata/" + strings.TrimPrefix(strings.TrimPrefix(path, mount.path), "data/"), nil
+	}
+
+	mountPath, secretPath, ok := strings.Cut(path, "/data/")
+	if !ok {
+		return "", fmt.Errorf("Could not find the KV-V2 mount of path %s to read its metadata", path)
+	}
+	return mountPath + "/metadata/" + secretPath, nil
+}
+
+// dynamicSecretData returns the data of a secret from a secrets engine other than KV, along with its lease
+func dynamicSecretData(path string, secret *api.Secret) map[string]interface{} {
+	data := make(map[string]interface{}, len(secret.Data)+3)
+	for k, v := range secret.Data {
+		data[k] = v
+	}
+
+	if secret.LeaseID != "" {
+		utils.VerboseToStdErr("Hashicorp Vault issued lease %s for path %s, valid for %ds, renewable: %t", secret.LeaseID, path, secret.LeaseDuration, secret.Renewable)
+		data["lease_id"] = secret.LeaseID
+		data["lease_duration"] = secret.LeaseDuration
+		data["renewable"] = secret.Renewable
+	}
+	return data
+}
+
+// detectKvVersion returns the KV version of the mount of path, and the path to read with it
+func (v *Vault) detectKvVersion(client *api.Client, path string) (string, string) {
+	mount, ok := v.lookupMount(client, path)
+	if !ok {
+		// Without access to the mount information, keep the historical default
+		utils.VerboseToStdErr("Hashicorp Vault assuming KV-V2 for path %s", path)
+		return "2", path
+	}
+
+	// KV-V2 secrets are read under data/ in the mount
+	if mount.kvVersion == "2" && strings.HasPrefix(path, mount.path) && !strings.HasPrefix(path, mount.path+"data/") {
+		path = mount.path + "data/" + strings.TrimPrefix(path, mount.path)
+	}
+	return mount.kvVersion, path
+}
+
+// lookupMount returns the mount of path, reading it from sys/internal/ui/mounts the first time
+func (v *Vault) lookupMount(client *api.Client, path string) (vaultMount, bool) {
+	if mount, ok := v.findMount(client.Namespace(), path); ok {
+		return mount, true
+	}
+
+	secret, err := client.Logical().Read(fmt.Sprintf("sys/internal/ui/mounts/%s", path))
+	if err != nil || secret == nil {
+		utils.VerboseToStdErr("Hashicorp Vault cannot detect the mount of path %s: %v", path, err)
+		return vaultMount{}, false
+	}
+
+	mount := vaultMount{
+		namespace: client.Namespace(),
+		path:      fmt.Sprint(secret.Data["path"]),
+		engine:    fmt.Sprint(secret.Data["type"]),
+		kvVersion: "1",
+	}
+	// Other secrets engines return their data as is, like KV-V1
+	if options, ok := secret.Data["options"].(map[string]interface{}); ok && mount.engine == "kv" && options["version"] == "2" {
+		mount.kvVersion = "2"
+	}
+	utils.VerboseToStdErr("Hashicorp Vault detected KV-V%s mount %s for path %s", mount.kvVersion, mount.path, path)
+
+	v.lock.Lock()
+	v.mounts = append(v.mounts, mount)
+	v.lock.Unlock()
+	return mount, true
+}
+
+func (v *Vault) findMount(namespace, path string) (vaultMount, bool) {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for _, mount := range v.mounts {
+		if mount.namespace == namespace && strings.HasPrefix(path, mount.path) {
+			return mount, true
+		}
+	}
+	return vaultMount{}, false
 }
@@ -1,13 +1,18 @@
 package backends_test
 
 import (
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/auth/vault"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/backends"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/hashicorp/vault/api"
 )
 
 func TestVaultLogin(t *testing.T) {
@@ -169,3 +174,322 @@ func TestVaultGetSecrets(t *testing.T) {
 	})
 
 }
+
+func TestVaultNamespace(t *testing.T) {
+	var namespaces []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		namespaces = append(namespaces, r.Header.Get("X-Vault-Namespace"))
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"data": {"data": {"hello": "world"}}}`))
+	}))
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	client.SetNamespace("team")
+	backend := backends.NewVaultBackend(&vault.TokenAuth{}, client, "2")
+
+	_, err = backend.GetSecrets("kv/data/test", "", map[string]string{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	_, err = backend.GetSecrets("kv/data/test", "", map[string]string{
+		types.VaultNamespaceAnnotation: "team/app",
+	})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	_, err = backend.GetSecrets("kv/data/test", "", map[string]string{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+
+	expected := []string{"team", "team/app", "team"}
+	if !reflect.DeepEqual(namespaces, expected) {
+		t.Errorf("expected: %s, got: %s.", expected, namespaces)
+	}
+}
+
+func TestVaultDetectKvVersion(t *testing.T) {
+	cluster, roleID, secretID := helpers.CreateTestAppRoleVault(t)
+	defer cluster.Cleanup()
+
+	auth := vault.NewAppRoleAuth(roleID, secretID, "")
+	backend := backends.NewVaultBackend(auth, cluster.Cores[0].Client, "")
+
+	testCases := map[string]struct {
+		path     string
+		expected map[string]interface{}
+	}{
+		"will insert data/ for kv2": {
+			"kv/test",
+			map[string]interface{}{"hello": "world"},
+		},
+		"will keep data/ for kv2": {
+			"kv/data/test",
+			map[string]interface{}{"hello": "world"},
+		},
+		"will detect kv1": {
+			"secret/foo",
+			map[string]interface{}{"secret": "bar"},
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			data, err := backend.GetSecrets(tc.path, "", map[string]string{})
+			if err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if !reflect.DeepEqual(data, tc.expected) {
+				t.Errorf("expected: %s, got: %s.", tc.expected, data)
+			}
+		})
+	}
+
+	t.Run("will not detect the version when it is set", func(t *testing.T) {
+		_, err := backend.GetSecrets("kv/test", "", map[string]string{
+			types.VaultKVVersionAnnotation: "2",
+		})
+		if err == nil {
+			t.Fatalf("expected an error but did not get an error")
+		}
+	})
+}
+
+func TestVaultDetectKvVersionOncePerMount(t *testing.T) {
+	var mountLookups int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if strings.HasPrefix(r.URL.Path, "/v1/sys/internal/ui/mounts/") {
+			mountLookups++
+			w.Write([]byte(`{"data": {"path": "team/", "type": "kv", "options": {"version": "2"}}}`))
+			return
+		}
+		w.Write([]byte(`{"data": {"data": {"path": "` + r.URL.Path + `"}}}`))
+	}))
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	backend := backends.NewVaultBackend(&vault.TokenAuth{}, client, "")
+
+	for _, path := range []string{"team/app", "team/data/db", "team/other"} {
+		_, err = backend.GetSecrets(path, "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+	}
+
+	data, _ := backend.GetSecrets("team/app", "", map[string]string{})
+	if data["path"] != "/v1/team/data/app" {
+		t.Errorf("expected: /v1/team/data/app, got: %s.", data["path"])
+	}
+	if mountLookups != 1 {
+		t.Errorf("expected 1 mount lookup, got: %d.", mountLookups)
+	}
+}
+
+func TestVaultDynamicSecrets(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		requests = append(requests, r.Method+" "+r.URL.Path+" "+string(body))
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/v1/pki/issue/web":
+			w.Write([]byte(`{"data": {"certificate": "cert", "private_key": "key"}}`))
+		case "/v1/database/creds/app":
+			w.Write([]byte(`{"lease_id": "database/creds/app/abcd", "lease_duration": 3600, "renewable": true, "data": {"username": "v-app", "password": "secret"}}`))
+		case "/v1/sys/internal/ui/mounts/kv/app":
+			w.Write([]byte(`{"data": {"path": "kv/", "type": "kv", "options": {"version": "1"}}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	config := api.DefaultConfig()
+	config.Address = server.URL
+	client, err := api.NewClient(config)
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	backend := backends.NewVaultBackend(&vault.TokenAuth{}, client, "2")
+	backend.AllowWrites = true
+
+	t.Run("will write request parameters", func(t *testing.T) {
+		requests = nil
+		data, err := backend.GetSecrets("pki/issue/web?common_name=app.example.com&ttl=24h", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+
+		expected := map[string]interface{}{
+			"certificate": "cert",
+			"private_key": "key",
+		}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("expected: %s, got: %s.", expected, data)
+		}
+
+		expectedRequests := []string{
+			"GET /v1/sys/internal/ui/mounts/pki/issue/web ",
+			`PUT /v1/pki/issue/web {"common_name":"app.example.com","ttl":"24h"}`,
+		}
+		if !reflect.DeepEqual(requests, expectedRequests) {
+			t.Errorf("expected: %s, got: %s.", expectedRequests, requests)
+		}
+	})
+
+	t.Run("will return leased credentials with their lease", func(t *testing.T) {
+		data, err := backend.GetSecrets("database/creds/app", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+
+		expected := map[string]interface{}{
+			"username":       "v-app",
+			"password":       "secret",
+			"lease_id":       "database/creds/app/abcd",
+			"lease_duration": 3600,
+			"renewable":      true,
+		}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("expected: %v, got: %v.", expected, data)
+		}
+	})
+
+	t.Run("will not write request parameters unless allowed", func(t *testing.T) {
+		requests = nil
+		readOnly := backends.NewVaultBackend(&vault.TokenAuth{}, client, "2")
+		_, err := readOnly.GetSecrets("pki/issue/web?common_name=app.example.com", "", map[string]string{})
+		expected := "Vault path pki/issue/web has request parameters, which are only written to Vault when AVP_VAULT_ALLOW_WRITES is true"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+		if len(requests) != 0 {
+			t.Errorf("expected no requests, got: %s.", requests)
+		}
+	})
+
+	t.Run("will not write request parameters to KV mounts", func(t *testing.T) {
+		requests = nil
+		_, err := backend.GetSecrets("kv/app?password=x", "", map[string]string{})
+		expected := "Vault path kv/app has request parameters, which cannot be written to the KV mount kv/"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+		for _, request := range requests {
+			if !strings.HasPrefix(request, "GET ") {
+				t.Errorf("expected only reads, got: %s.", requests)
+			}
+		}
+	})
+
+	t.Run("will report invalid request parameters", func(t *testing.T) {
+		_, err := backend.GetSecrets("pki/issue/web?common_name=%zz", "", map[string]string{})
+		expected := `Invalid request parameters in Vault path pki/issue/web: invalid URL escape "%zz"`
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+}
+
+func TestVaultMetadata(t *testing.T) {
+	cluster, roleID, secretID := helpers.CreateTestAppRoleVault(t)
+	defer cluster.Cleanup()
+
+	_, err := cluster.Cores[0].Client.Logical().Write("kv/metadata/versioned", map[string]interface{}{
+		"custom_metadata": map[string]interface{}{
+			"owner": "team-a",
+		},
+	})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+
+	auth := vault.NewAppRoleAuth(roleID, secretID, "")
+	backend := backends.NewVaultBackend(auth, cluster.Cores[0].Client, "2")
+
+	t.Run("will return the metadata of the version", func(t *testing.T) {
+		data, err := backend.GetSecrets("kv/data/versioned", "1", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		expected := map[string]interface{}{
+			"secret": "version1",
+		}
+		if !reflect.DeepEqual(data, expected) {
+			t.Errorf("expected: %s, got: %s.", expected, data)
+		}
+
+		for key, expected := range map[string]string{
+			"@metadata.version":               "1",
+			"@metadata.custom_metadata.owner": "team-a",
+			"@metadata.destroyed":             "false",
+		} {
+			value, err := backend.GetIndividualSecret("kv/data/versioned", key, "1", map[string]string{})
+			if err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if value != expected {
+				t.Errorf("expected: %s for %s, got: %v.", expected, key, value)
+			}
+		}
+
+		value, err := backend.GetIndividualSecret("kv/data/versioned", "@metadata.created_time", "1", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value == nil {
+			t.Errorf("expected the created_time of the version")
+		}
+	})
+
+	t.Run("will read the metadata endpoint when needed", func(t *testing.T) {
+		value, err := backend.GetIndividualSecret("kv/data/versioned", "@metadata.current_version", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value != "2" {
+			t.Errorf("expected: 2, got: %v.", value)
+		}
+	})
+
+	t.Run("will read the metadata endpoint of detected mounts", func(t *testing.T) {
+		backend := backends.NewVaultBackend(auth, cluster.Cores[0].Client, "")
+		value, err := backend.GetIndividualSecret("kv/versioned", "@metadata.updated_time", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value == nil {
+			t.Errorf("expected the updated_time of the secret")
+		}
+	})
+	t.Run("will read the metadata endpoint through the cache", func(t *testing.T) {
+		cache := backends.NewCacheBackend(backend)
+		value, err := cache.GetIndividualSecret("kv/data/versioned", "@metadata.custom_metadata.owner", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value != "team-a" {
+			t.Errorf("expected: team-a, got: %v.", value)
+		}
+
+		value, err = cache.GetIndividualSecret("kv/data/versioned", "@metadata.current_version", "", map[string]string{})
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if value != "2" {
+			t.Errorf("expected: 2, got: %v.", value)
+		}
+	})
+}
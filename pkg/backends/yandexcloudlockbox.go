@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	"github.com/yandex-cloud/go-genproto/yandex/cloud/lockbox/v1"
 )
@@ -60,7 +61,7 @@ func (ycl *YandexCloudLockbox) GetIndividualSecret(secretID, key, version string
 
 	secret, found := secrets[key]
 	if !found {
-		return nil, fmt.Errorf("secretID: %s, key: %s, version: %s not found", secretID, key, version)
+		return nil, &types.NotFoundError{Err: fmt.Errorf("secretID: %s, key: %s, version: %s not found", secretID, key, version)}
 	}
 
 	return secret, nil
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/Azure/azure-sdk-for-go/sdk/security/keyvault/azsecrets"
 	"os"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -29,6 +30,7 @@ import (
 	ycsdk "github.com/yandex-cloud/go-sdk"
 	"github.com/yandex-cloud/go-sdk/iamkey"
 	sops "go.mozilla.org/sops/v3/decrypt"
+	iamcredentials "google.golang.org/api/iamcredentials/v1"
 )
 
 // Options options that can be passed to a Config struct
@@ -42,6 +44,8 @@ type Config struct {
 	Backend types.Backend
 }
 
+var backendName = regexp.MustCompile(`^\w+$`)
+
 var backendPrefixes []string = []string{
 	"vault",
 	"aws",
@@ -52,40 +56,240 @@ var backendPrefixes []string = []string{
 	"k8s_secret",
 }
 
-// New returns a new Config struct
-func New(v *viper.Viper, co *Options) (*Config, error) {
-	// Set Defaults
-	v.SetDefault(types.EnvAvpKvVersion, "2")
-
+// ReadSettings reads the configuration from the Kubernetes Secret or config file given in co, and from the environment,
+// into v without instantiating a backend
+func ReadSettings(v *viper.Viper, co *Options) error {
 	// Read in config file or kubernetes secret and set as env vars
 	err := readConfigOrSecret(co.SecretName, co.ConfigPath, v)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
 	// Instantiate Env
 	utils.VerboseToStdErr("reading configuration from environment, overriding any previous settings")
 	v.AutomaticEnv()
 
+	// The token cache is shared by the Vault auth methods of every backend
+	viper.Set("tokenCacheDir", v.GetString(types.EnvAvpTokenCacheDir))
+
 	utils.VerboseToStdErr("AVP configured with the following settings:\n")
 	for k, viperValue := range v.AllSettings() {
 		utils.VerboseToStdErr("%s: %s\n", k, viperValue)
 	}
 
+	return nil
+}
+
+// New returns a new Config struct
+func New(v *viper.Viper, co *Options) (*Config, error) {
+	err := ReadSettings(v, co)
+	if err != nil {
+		return nil, err
+	}
+
+	backend, err := defaultBackend(v)
+	if err != nil {
+		return nil, err
+	}
+
+	backendsSetting := strings.TrimSpace(v.GetString(types.EnvAvpBackends))
+	if backendsSetting == "" {
+		return &Config{
+			Backend: backend,
+		}, nil
+	}
+
+	// AVP_BACKENDS names backends that placeholders can select, each with its own prefixed settings
+	named := make(map[string]types.Backend)
+	var first types.Backend
+	for _, entry := range strings.Split(backendsSetting, ",") {
+		name, backendType, ok := strings.Cut(strings.TrimSpace(entry), ":")
+		if !ok || !backendName.MatchString(name) {
+			return nil, fmt.Errorf("%s must be a comma separated list of <name>:<type>, with names made of letters, digits and underscores, received %s", types.EnvAvpBackends, backendsSetting)
+		}
+		if _, ok := named[name]; ok {
+			return nil, fmt.Errorf("backend %s is defined more than once in %s", name, types.EnvAvpBackends)
+		}
+
+		utils.VerboseToStdErr("configuring backend %s of type %s", name, backendType)
+		namedBackend, err := newBackend(strings.TrimSpace(backendType), backendSettings(v, name))
+		if err != nil {
+			return nil, fmt.Errorf("backend %s: %s", name, err)
+		}
+		named[name] = namedBackend
+		if first == nil {
+			first = namedBackend
+		}
+	}
+
+	// Without AVP_TYPE, the first named backend is used by placeholders that select none
+	if backend == nil {
+		backend = first
+	}
+
+	return &Config{
+		Backend: backends.NewRegistryBackend(backend, named),
+	}, nil
+}
+
+// defaultBackend builds the backend given by AVP_TYPE, or nil if AVP_BACKENDS is used instead
+func defaultBackend(v *viper.Viper) (types.Backend, error) {
+	if strings.TrimSpace(v.GetString(types.EnvAvpType)) == "" && strings.TrimSpace(v.GetString(types.EnvAvpBackends)) != "" {
+		return nil, nil
+	}
+
+	// AVP_TYPE may list several backends, each tried in turn for every lookup
+	var chain []types.Backend
+	for _, backendType := range strings.Split(v.GetString(types.EnvAvpType), ",") {
+		backend, err := newBackend(strings.TrimSpace(backendType), v) // strip whitespace and newlines
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, backend)
+	}
+
+	if len(chain) == 1 {
+		return chain[0], nil
+	}
+
+	utils.VerboseToStdErr("chaining backends %s", v.GetString(types.EnvAvpType))
+	return backends.NewChainBackend(chain...), nil
+}
+
+// backendSettings returns the settings of the named backend `name`. Settings prefixed with the upper-cased name,
+// e.g. CORP_AVP_AUTH_TYPE for the backend corp, override the unprefixed ones
+func backendSettings(v *viper.Viper, name string) *viper.Viper {
+	settings := viper.New()
+	settings.AutomaticEnv()
+	for key, value := range v.AllSettings() {
+		settings.Set(key, value)
+	}
+
+	prefix := strings.ToUpper(name) + "_"
+	for _, envVar := range os.Environ() {
+		if strings.HasPrefix(envVar, prefix) {
+			envVarPair := strings.SplitN(envVar, "=", 2)
+			settings.Set(strings.TrimPrefix(envVarPair[0], prefix), envVarPair[1])
+		}
+	}
+	// Prefixed settings from a config file, Kubernetes Secret or ARGOCD_ENV_ variable
+	for key, value := range v.AllSettings() {
+		if strings.HasPrefix(strings.ToUpper(key), prefix) {
+			settings.Set(strings.ToUpper(key)[len(prefix):], value)
+		}
+	}
+
+	return settings
+}
+
+// clientCertificate returns the PEM encoded client certificate and key to present to Vault, if configured,
+// either inline or from files
+func clientCertificate(v *viper.Viper) ([]byte, []byte, error) {
+	read := func(inline, path string) ([]byte, error) {
+		if v.IsSet(inline) {
+			return []byte(v.GetString(inline)), nil
+		}
+		if v.IsSet(path) {
+			data, err := os.ReadFile(v.GetString(path))
+			if err != nil {
+				return nil, fmt.Errorf("could not read %s: %s", path, err)
+			}
+			return data, nil
+		}
+		return nil, nil
+	}
+
+	certificate, err := read(types.EnvAvpClientCert, types.EnvAvpClientCertPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := read(types.EnvAvpClientKey, types.EnvAvpClientKeyPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if (certificate == nil) != (key == nil) {
+		return nil, nil, fmt.Errorf("both a client certificate and a client key must be provided, with %s or %s and %s or %s",
+			types.EnvAvpClientCert, types.EnvAvpClientCertPath, types.EnvAvpClientKey, types.EnvAvpClientKeyPath)
+	}
+	return certificate, key, nil
+}
+
+// vaultAPIConfig returns the configuration of the Vault client of a backend from the settings in v
+func vaultAPIConfig(v *viper.Viper) (*api.Config, error) {
+	// The settings of named backends are not in the environment the Vault client reads
+	apiConfig := api.DefaultConfig()
+	if apiConfig.Error != nil {
+		return nil, apiConfig.Error
+	}
+	if v.IsSet(types.EnvVaultAddress) {
+		apiConfig.Address = v.GetString(types.EnvVaultAddress)
+	}
+	certificate, key, err := clientCertificate(v)
+	if err != nil {
+		return nil, err
+	}
+	if certificate != nil {
+		err = vault.ConfigureClientCertificate(apiConfig, certificate, key)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return apiConfig, nil
+}
+
+// VaultAPIConfig returns the configuration of a Vault client for the server at address, built from the settings
+// in v like the Vault backends are, so that it presents the same client certificate. The settings of the backend
+// of AVP_BACKENDS whose VAULT_ADDR is address are used, if the default settings are for another server
+func VaultAPIConfig(v *viper.Viper, address string) (*api.Config, error) {
+	settings := v
+	if v.GetString(types.EnvVaultAddress) != address {
+		for _, entry := range strings.Split(v.GetString(types.EnvAvpBackends), ",") {
+			name, _, ok := strings.Cut(strings.TrimSpace(entry), ":")
+			if !ok {
+				continue
+			}
+			if named := backendSettings(v, name); named.GetString(types.EnvVaultAddress) == address {
+				settings = named
+				break
+			}
+		}
+	}
+
+	apiConfig, err := vaultAPIConfig(settings)
+	if err != nil {
+		return nil, err
+	}
+	if address != "" {
+		apiConfig.Address = address
+	}
+	return apiConfig, nil
+}
+
+// newBackend builds the backend of the given type from the settings in v
+func newBackend(backendType string, v *viper.Viper) (types.Backend, error) {
 	authType := strings.TrimSpace(v.GetString(types.EnvAvpAuthType)) // strip whitespace and newlines
 
 	var auth types.AuthType
 	var backend types.Backend
 
-	backendType := strings.TrimSpace(v.GetString(types.EnvAvpType)) // strip whitespace and newlines
-
 	switch backendType {
 	case types.VaultBackend:
 		{
-			apiClient, err := api.NewClient(api.DefaultConfig())
+			apiConfig, err := vaultAPIConfig(v)
 			if err != nil {
 				return nil, err
 			}
+			apiClient, err := api.NewClient(apiConfig)
+			if err != nil {
+				return nil, err
+			}
+			if v.IsSet(api.EnvVaultToken) {
+				apiClient.SetToken(v.GetString(api.EnvVaultToken))
+			}
+			if v.IsSet(types.EnvAvpVaultNamespace) {
+				apiClient.SetNamespace(v.GetString(types.EnvAvpVaultNamespace))
+			}
 
 			switch authType {
 			case types.ApproleAuth:
@@ -119,6 +323,52 @@ func New(v *viper.Viper, co *Options) (*Config, error) {
 				} else {
 					return nil, fmt.Errorf("%s cannot be empty when using Kubernetes Auth", types.EnvAvpK8sRole)
 				}
+			case types.JWTAuth:
+				if v.IsSet(types.EnvAvpJWTRole) && v.IsSet(types.EnvAvpJWTTokenPath) {
+					auth = vault.NewJWTAuth(v.GetString(types.EnvAvpJWTRole), v.GetString(types.EnvAvpJWTTokenPath), v.GetString(types.EnvAvpMountPath))
+				} else {
+					return nil, fmt.Errorf("%s and %s for jwt authentication cannot be empty", types.EnvAvpJWTRole, types.EnvAvpJWTTokenPath)
+				}
+			case types.CertAuth:
+				// Any error reading the certificate was returned by vaultAPIConfig
+				if certificate, _, _ := clientCertificate(v); certificate != nil {
+					auth = vault.NewCertAuth(v.GetString(types.EnvAvpCertRole), v.GetString(types.EnvAvpMountPath))
+				} else {
+					return nil, fmt.Errorf("%s or %s for cert authentication cannot be empty", types.EnvAvpClientCert, types.EnvAvpClientCertPath)
+				}
+			case types.IAMAuth:
+				s, err := config.LoadDefaultConfig(context.TODO())
+				if err != nil {
+					return nil, err
+				}
+				auth = vault.NewIAMAuth(v.GetString(types.EnvAvpIAMRole), v.GetString(types.EnvAvpIAMServerID), v.GetString(types.EnvAvpMountPath), s.Credentials)
+			case types.AzureAuth:
+				if v.IsSet(types.EnvAvpAzureRole) {
+					cred, err := azidentity.NewDefaultAzureCredential(nil)
+					if err != nil {
+						return nil, err
+					}
+					auth = vault.NewAzureAuth(
+						v.GetString(types.EnvAvpAzureRole),
+						v.GetString(types.EnvAvpAzureResource),
+						v.GetString(types.EnvAvpAzureSubID),
+						v.GetString(types.EnvAvpAzureGroup),
+						v.GetString(types.EnvAvpMountPath),
+						cred,
+					)
+				} else {
+					return nil, fmt.Errorf("%s cannot be empty when using Azure Auth", types.EnvAvpAzureRole)
+				}
+			case types.GCPAuth:
+				if v.IsSet(types.EnvAvpGCPRole) {
+					iam, err := iamcredentials.NewService(context.Background())
+					if err != nil {
+						return nil, err
+					}
+					auth = vault.NewGCPAuth(v.GetString(types.EnvAvpGCPRole), v.GetString(types.EnvAvpGCPAccount), v.GetString(types.EnvAvpMountPath), iam)
+				} else {
+					return nil, fmt.Errorf("%s cannot be empty when using GCP Auth", types.EnvAvpGCPRole)
+				}
 			case types.TokenAuth:
 				if v.IsSet(api.EnvVaultToken) {
 					auth = &vault.TokenAuth{}
@@ -134,7 +384,9 @@ func New(v *viper.Viper, co *Options) (*Config, error) {
 			default:
 				return nil, fmt.Errorf("Must provide a supported Authentication Type, received %s", authType)
 			}
-			backend = backends.NewVaultBackend(auth, apiClient, v.GetString(types.EnvAvpKvVersion))
+			vaultBackend := backends.NewVaultBackend(auth, apiClient, v.GetString(types.EnvAvpKvVersion))
+			vaultBackend.AllowWrites = v.GetBool(types.EnvAvpVaultAllowWrites)
+			backend = vaultBackend
 		}
 	case types.IBMSecretsManagerbackend:
 		{
@@ -266,7 +518,7 @@ func New(v *viper.Viper, co *Options) (*Config, error) {
 				)
 			}
 
-			tss, _ := delineasecretserver.New(delineasecretserver.Configuration{
+			tss, err := delineasecretserver.New(delineasecretserver.Configuration{
 				Credentials: delineasecretserver.UserCredential{
 					Username: v.GetString(types.EnvAvpDelineaUser),
 					Password: v.GetString(types.EnvAvpDelineaPassword),
@@ -284,12 +536,10 @@ func New(v *viper.Viper, co *Options) (*Config, error) {
 			backend = backends.NewKubernetesSecret()
 		}
 	default:
-		return nil, fmt.Errorf("Must provide a supported Vault Type, received %s", v.GetString(types.EnvAvpType))
+		return nil, fmt.Errorf("Must provide a supported Vault Type, received %s", backendType)
 	}
 
-	return &Config{
-		Backend: backend,
-	}, nil
+	return backend, nil
 }
 
 func readConfigOrSecret(secretName, configPath string, v *viper.Viper) error {
@@ -4,11 +4,14 @@ import (
 	"bytes"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
 	"strings"
 	"testing"
 
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/backends"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/config"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
 	"github.com/spf13/viper"
 )
 
@@ -78,6 +81,45 @@ func TestNewConfig(t *testing.T) {
 			},
 			"*backends.Vault",
 		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":           "vault",
+				"AVP_AUTH_TYPE":      "jwt",
+				"AVP_JWT_ROLE":       "role",
+				"AVP_JWT_TOKEN_PATH": "/var/run/secrets/tokens/vault-token",
+			},
+			"*backends.Vault",
+		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":             "vault",
+				"AVP_AUTH_TYPE":        "cert",
+				"AVP_CERT_ROLE":        "argocd",
+				"AVP_CLIENT_CERT_PATH": "../../fixtures/input/client.crt",
+				"AVP_CLIENT_KEY_PATH":  "../../fixtures/input/client.key",
+			},
+			"*backends.Vault",
+		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":          "vault",
+				"AVP_AUTH_TYPE":     "iam",
+				"AVP_IAM_ROLE":      "argocd",
+				"AVP_IAM_SERVER_ID": "vault.example.com",
+			},
+			"*backends.Vault",
+		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":            "vault",
+				"AVP_AUTH_TYPE":       "azure",
+				"AVP_AZURE_ROLE":      "argocd",
+				"AZURE_TENANT_ID":     "test",
+				"AZURE_CLIENT_ID":     "test",
+				"AZURE_CLIENT_SECRET": "test",
+			},
+			"*backends.Vault",
+		},
 		{
 			map[string]interface{}{
 				"AVP_TYPE":      "vault",
@@ -224,6 +266,17 @@ fDGt+yaf3RaZbVwHSVLzxiXGsu1WQJde3uJeNh5c6z+5
 			},
 			"*backends.KubernetesSecret",
 		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":              "vault, awssecretsmanager",
+				"AVP_AUTH_TYPE":         "token",
+				"VAULT_TOKEN":           "token",
+				"AWS_REGION":            "us-west-1",
+				"AWS_ACCESS_KEY_ID":     "id",
+				"AWS_SECRET_ACCESS_KEY": "key",
+			},
+			"*backends.Chain",
+		},
 	}
 	for _, tc := range testCases {
 		for k, v := range tc.environment {
@@ -255,6 +308,254 @@ func TestNewConfigNoType(t *testing.T) {
 	}
 }
 
+func TestNewConfigChainInvalidType(t *testing.T) {
+	os.Setenv("AVP_TYPE", "sops,not-valid-type")
+	viper := viper.New()
+	_, err := config.New(viper, &config.Options{})
+	expectedError := "Must provide a supported Vault Type, received not-valid-type"
+
+	if err == nil || err.Error() != expectedError {
+		t.Errorf("expected error %s to be thrown, got %s", expectedError, err)
+	}
+	os.Unsetenv("AVP_TYPE")
+}
+
+func TestNewConfigNamedBackends(t *testing.T) {
+	os.Setenv("AVP_BACKENDS", "corp:vault,cloud:awssecretsmanager")
+	os.Setenv("CORP_AVP_AUTH_TYPE", "token")
+	os.Setenv("CORP_VAULT_TOKEN", "token")
+	os.Setenv("CORP_VAULT_ADDR", "http://vault.corp:8200")
+	os.Setenv("AWS_REGION", "us-west-1")
+	viper := viper.New()
+	config, err := config.New(viper, &config.Options{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+
+	registry, ok := config.Backend.(*backends.Registry)
+	if !ok {
+		t.Fatalf("expected: *backends.Registry, got: %T.", config.Backend)
+	}
+	if registry.Default != registry.Named["corp"] {
+		t.Errorf("expected the first named backend to be the default")
+	}
+	if xType := fmt.Sprintf("%T", registry.Named["cloud"]); xType != "*backends.AWSSecretsManager" {
+		t.Errorf("expected: *backends.AWSSecretsManager, got: %s.", xType)
+	}
+	for _, k := range []string{"AVP_BACKENDS", "CORP_AVP_AUTH_TYPE", "CORP_VAULT_TOKEN", "CORP_VAULT_ADDR", "AWS_REGION"} {
+		os.Unsetenv(k)
+	}
+}
+
+func TestNewConfigNamedBackendsInvalid(t *testing.T) {
+	testCases := map[string]struct {
+		backends      string
+		expectedError string
+	}{
+		"missing type": {
+			"corp",
+			"AVP_BACKENDS must be a comma separated list of <name>:<type>, with names made of letters, digits and underscores, received corp",
+		},
+		"invalid name": {
+			"corp-vault:vault",
+			"AVP_BACKENDS must be a comma separated list of <name>:<type>, with names made of letters, digits and underscores, received corp-vault:vault",
+		},
+		"duplicate name": {
+			"corp:sops,corp:sops",
+			"backend corp is defined more than once in AVP_BACKENDS",
+		},
+		"invalid type": {
+			"corp:not-valid-type",
+			"backend corp: Must provide a supported Vault Type, received not-valid-type",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv("AVP_BACKENDS", tc.backends)
+			defer os.Unsetenv("AVP_BACKENDS")
+			viper := viper.New()
+			_, err := config.New(viper, &config.Options{})
+			if err == nil || err.Error() != tc.expectedError {
+				t.Errorf("expected error %s to be thrown, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestNewConfigClientCertificate(t *testing.T) {
+	certificate, _ := os.ReadFile("../../fixtures/input/client.crt")
+	key, _ := os.ReadFile("../../fixtures/input/client.key")
+
+	testCases := map[string]struct {
+		environment   map[string]string
+		expectedError string
+	}{
+		"inline certificate": {
+			map[string]string{
+				"AVP_AUTH_TYPE":   "cert",
+				"AVP_CLIENT_CERT": string(certificate),
+				"AVP_CLIENT_KEY":  string(key),
+			},
+			"",
+		},
+		"certificate without key": {
+			map[string]string{
+				"AVP_AUTH_TYPE":        "cert",
+				"AVP_CLIENT_CERT_PATH": "../../fixtures/input/client.crt",
+			},
+			"both a client certificate and a client key must be provided, with AVP_CLIENT_CERT or AVP_CLIENT_CERT_PATH and AVP_CLIENT_KEY or AVP_CLIENT_KEY_PATH",
+		},
+		"invalid certificate": {
+			map[string]string{
+				"AVP_AUTH_TYPE":   "cert",
+				"AVP_CLIENT_CERT": "not a certificate",
+				"AVP_CLIENT_KEY":  string(key),
+			},
+			"could not load client certificate: tls: failed to find any PEM data in certificate input",
+		},
+		"no certificate": {
+			map[string]string{
+				"AVP_AUTH_TYPE": "cert",
+			},
+			"AVP_CLIENT_CERT or AVP_CLIENT_CERT_PATH for cert authentication cannot be empty",
+		},
+		"certificate with another auth type": {
+			map[string]string{
+				"AVP_AUTH_TYPE":        "token",
+				"VAULT_TOKEN":          "token",
+				"AVP_CLIENT_CERT_PATH": "../../fixtures/input/client.crt",
+				"AVP_CLIENT_KEY_PATH":  "../../fixtures/input/client.key",
+			},
+			"",
+		},
+	}
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			os.Setenv("AVP_TYPE", "vault")
+			defer os.Unsetenv("AVP_TYPE")
+			for k, v := range tc.environment {
+				os.Setenv(k, v)
+				defer os.Unsetenv(k)
+			}
+
+			viper := viper.New()
+			_, err := config.New(viper, &config.Options{})
+			if tc.expectedError == "" && err != nil {
+				t.Fatalf("expected 0 errors but got: %s", err)
+			}
+			if tc.expectedError != "" && (err == nil || err.Error() != tc.expectedError) {
+				t.Errorf("expected error %s to be thrown, got %v", tc.expectedError, err)
+			}
+		})
+	}
+}
+
+func TestVaultAPIConfig(t *testing.T) {
+	environment := map[string]string{
+		"VAULT_ADDR":           "https://vault.example.com",
+		"AVP_CLIENT_CERT_PATH": "../../fixtures/input/client.crt",
+		"AVP_CLIENT_KEY_PATH":  "../../fixtures/input/client.key",
+		"AVP_BACKENDS":         "corp:vault",
+		"CORP_VAULT_ADDR":      "https://vault.corp:8200",
+		"CORP_AVP_CLIENT_CERT": "not a certificate",
+	}
+	for k, v := range environment {
+		os.Setenv(k, v)
+		defer os.Unsetenv(k)
+	}
+	v := viper.New()
+	if err := config.ReadSettings(v, &config.Options{}); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("will present the client certificate to the server of a cached token", func(t *testing.T) {
+		apiConfig, err := config.VaultAPIConfig(v, "https://other.example.com")
+		if err != nil {
+			t.Fatalf("expected 0 errors but got: %s", err)
+		}
+		if apiConfig.Address != "https://other.example.com" {
+			t.Errorf("expected: https://other.example.com, got: %s.", apiConfig.Address)
+		}
+		if apiConfig.HttpClient.Transport.(*http.Transport).TLSClientConfig.GetClientCertificate == nil {
+			t.Errorf("expected the client certificate to be configured")
+		}
+	})
+
+	t.Run("will use the settings of the named backend of the server", func(t *testing.T) {
+		_, err := config.VaultAPIConfig(v, "https://vault.corp:8200")
+		expected := "could not load client certificate: tls: failed to find any PEM data in certificate input"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected error %s to be thrown, got %v", expected, err)
+		}
+	})
+}
+
+func TestNewConfigVaultNamespace(t *testing.T) {
+	os.Setenv("AVP_TYPE", "vault")
+	os.Setenv("AVP_AUTH_TYPE", "token")
+	os.Setenv("VAULT_TOKEN", "token")
+	os.Setenv("AVP_VAULT_NAMESPACE", "team")
+	viper := viper.New()
+	config, err := config.New(viper, &config.Options{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+
+	namespace := config.Backend.(*backends.Vault).VaultClient.Namespace()
+	if namespace != "team" {
+		t.Errorf("expected: team, got: %s.", namespace)
+	}
+	for _, k := range []string{"AVP_TYPE", "AVP_AUTH_TYPE", "VAULT_TOKEN", "AVP_VAULT_NAMESPACE"} {
+		os.Unsetenv(k)
+	}
+}
+
+func TestNewConfigVaultAllowWrites(t *testing.T) {
+	os.Setenv("AVP_TYPE", "vault")
+	os.Setenv("AVP_AUTH_TYPE", "token")
+	os.Setenv("VAULT_TOKEN", "token")
+	viper := viper.New()
+	defaultConfig, err := config.New(viper, &config.Options{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	if defaultConfig.Backend.(*backends.Vault).AllowWrites {
+		t.Errorf("expected writes to be disallowed by default")
+	}
+
+	os.Setenv("AVP_VAULT_ALLOW_WRITES", "true")
+	writeConfig, err := config.New(viper, &config.Options{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+	if !writeConfig.Backend.(*backends.Vault).AllowWrites {
+		t.Errorf("expected writes to be allowed with AVP_VAULT_ALLOW_WRITES")
+	}
+	for _, k := range []string{"AVP_TYPE", "AVP_AUTH_TYPE", "VAULT_TOKEN", "AVP_VAULT_ALLOW_WRITES"} {
+		os.Unsetenv(k)
+	}
+}
+
+func TestNewConfigTokenCacheDir(t *testing.T) {
+	os.Setenv("AVP_TYPE", "vault")
+	os.Setenv("AVP_AUTH_TYPE", "token")
+	os.Setenv("VAULT_TOKEN", "token")
+	os.Setenv("AVP_TOKEN_CACHE_DIR", "/tmp/avp-tokens")
+	viper := viper.New()
+	_, err := config.New(viper, &config.Options{})
+	if err != nil {
+		t.Fatalf("expected 0 errors but got: %s", err)
+	}
+
+	dir, err := utils.TokenCacheDir()
+	if err != nil || dir != "/tmp/avp-tokens" {
+		t.Errorf("expected: /tmp/avp-tokens, got: %s.", dir)
+	}
+	for _, k := range []string{"AVP_TYPE", "AVP_AUTH_TYPE", "VAULT_TOKEN", "AVP_TOKEN_CACHE_DIR"} {
+		os.Unsetenv(k)
+	}
+}
+
 func TestNewConfigNoAuthType(t *testing.T) {
 	os.Setenv("AVP_TYPE", "vault")
 	viper := viper.New()
@@ -372,6 +673,28 @@ func TestNewConfigMissingParameter(t *testing.T) {
 			},
 			"*backends.Vault",
 		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":      "vault",
+				"AVP_AUTH_TYPE": "jwt",
+				"AVP_JWT_ROLE":  "role",
+			},
+			"*backends.Vault",
+		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":      "vault",
+				"AVP_AUTH_TYPE": "azure",
+			},
+			"*backends.Vault",
+		},
+		{
+			map[string]interface{}{
+				"AVP_TYPE":      "vault",
+				"AVP_AUTH_TYPE": "gcp",
+			},
+			"*backends.Vault",
+		},
 		{
 			map[string]interface{}{
 				"AVP_TYPE":      "vault",
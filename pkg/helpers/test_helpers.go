@@ -303,6 +303,11 @@ func CreateTestAuthVault(t *testing.T) *vault.TestCluster {
 		CredentialBackends: map[string]logical.Factory{
 			"github":     Factory,
 			"kubernetes": Factory,
+			"jwt":        Factory,
+			"cert":       Factory,
+			"aws":        Factory,
+			"azure":      Factory,
+			"gcp":        Factory,
 			"ibmcloud":   Factory,
 		},
 	}
@@ -337,6 +342,36 @@ func CreateTestAuthVault(t *testing.T) *vault.TestCluster {
 		t.Fatal(err)
 	}
 
+	if err := client.Sys().EnableAuthWithOptions("jwt", &api.EnableAuthOptions{
+		Type: "jwt",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Sys().EnableAuthWithOptions("cert", &api.EnableAuthOptions{
+		Type: "cert",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Sys().EnableAuthWithOptions("aws", &api.EnableAuthOptions{
+		Type: "aws",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Sys().EnableAuthWithOptions("azure", &api.EnableAuthOptions{
+		Type: "azure",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := client.Sys().EnableAuthWithOptions("gcp", &api.EnableAuthOptions{
+		Type: "gcp",
+	}); err != nil {
+		t.Fatal(err)
+	}
+
 	if err := client.Sys().EnableAuthWithOptions("ibmcloud", &api.EnableAuthOptions{
 		Type: "ibmcloud",
 	}); err != nil {
@@ -0,0 +1,103 @@
+package kube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+// checksumKinds are the kinds whose pods are restarted when their pod template changes
+var checksumKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+}
+
+// InjectChecksum annotates the pod template of the Template with the sha256 of the manifests named by its
+// `avp.kubernetes.io/checksum-from` annotation, so that its pods restart whenever their secret values change.
+// The named manifests are looked up by kind and name among `rendered`, in the Template's namespace
+func (t *Template) InjectChecksum(rendered []*Template) error {
+	checksumFrom := t.Annotations[types.AVPChecksumFromAnnotation]
+	if checksumFrom == "" {
+		return nil
+	}
+	if !checksumKinds[t.Kind] {
+		return fmt.Errorf("%s annotation can only be used on Deployment, StatefulSet or DaemonSet resources", types.AVPChecksumFromAnnotation)
+	}
+
+	hash := sha256.New()
+	for _, ref := range strings.Split(checksumFrom, ",") {
+		kind, name, ok := strings.Cut(strings.TrimSpace(ref), "/")
+		if !ok || kind == "" || name == "" {
+			return fmt.Errorf("%s annotation must be a comma separated list of <kind>/<name>, got %s", types.AVPChecksumFromAnnotation, checksumFrom)
+		}
+
+		source := findTemplate(rendered, kind, t.namespace(), name)
+		if source == nil {
+			return fmt.Errorf("%s: could not find %s in namespace %q among the generated manifests", types.AVPChecksumFromAnnotation, ref, t.namespace())
+		}
+
+		data, err := json.Marshal(source.checksumData())
+		if err != nil {
+			return fmt.Errorf("%s: could not compute the checksum of %s: %s", types.AVPChecksumFromAnnotation, ref, err)
+		}
+		hash.Write(data)
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	podTemplate := nestedMap(t.TemplateData, "spec", "template", "metadata", "annotations")
+	podTemplate[types.AVPChecksumAnnotation] = checksum
+
+	utils.VerboseToStdErr("annotated the pod template of %s %s with the checksum of %s", t.Kind, t.name(), checksumFrom)
+	return nil
+}
+
+func (t *Template) namespace() string {
+	metadata, _ := t.TemplateData["metadata"].(map[string]interface{})
+	namespace, _ := metadata["namespace"].(string)
+	return namespace
+}
+
+func (t *Template) name() string {
+	metadata, _ := t.TemplateData["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	return name
+}
+
+// checksumData is the part of the Template that changes with its secret values
+func (t *Template) checksumData() map[string]interface{} {
+	data := make(map[string]interface{})
+	for key, value := range t.TemplateData {
+		if key != "metadata" && key != "status" {
+			data[key] = value
+		}
+	}
+	return data
+}
+
+func findTemplate(templates []*Template, kind, namespace, name string) *Template {
+	for _, candidate := range templates {
+		if strings.EqualFold(candidate.Kind, kind) && candidate.namespace() == namespace && candidate.name() == name {
+			return candidate
+		}
+	}
+	return nil
+}
+
+// nestedMap returns the map at the given path below obj, creating any missing level
+func nestedMap(obj map[string]interface{}, path ...string) map[string]interface{} {
+	for _, key := range path {
+		inner, ok := obj[key].(map[string]interface{})
+		if !ok {
+			inner = make(map[string]interface{})
+			obj[key] = inner
+		}
+		obj = inner
+	}
+	return obj
+}
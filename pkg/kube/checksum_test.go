@@ -0,0 +1,128 @@
+package kube
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+)
+
+func checksumTestTemplate(kind, name string, annotations map[string]string, fields map[string]interface{}) *Template {
+	data := map[string]interface{}{
+		"kind": kind,
+		"metadata": map[string]interface{}{
+			"namespace": "default",
+			"name":      name,
+		},
+	}
+	for key, value := range fields {
+		data[key] = value
+	}
+
+	return &Template{
+		Resource{
+			Kind:         kind,
+			TemplateData: data,
+			Annotations:  annotations,
+		},
+	}
+}
+
+func TestInjectChecksum(t *testing.T) {
+	secret := checksumTestTemplate("Secret", "app-secret", nil, map[string]interface{}{
+		"stringData": map[string]interface{}{
+			"password": "hunter2",
+		},
+	})
+	config := checksumTestTemplate("ConfigMap", "app-config", nil, map[string]interface{}{
+		"data": map[string]interface{}{
+			"level": "debug",
+		},
+	})
+	rendered := []*Template{secret, config}
+
+	t.Run("will annotate the pod template with the checksum of the referenced manifests", func(t *testing.T) {
+		deployment := checksumTestTemplate("Deployment", "app", map[string]string{
+			types.AVPChecksumFromAnnotation: "secret/app-secret, ConfigMap/app-config",
+		}, map[string]interface{}{
+			"spec": map[string]interface{}{
+				"template": map[string]interface{}{
+					"spec": map[string]interface{}{},
+				},
+			},
+		})
+
+		err := deployment.InjectChecksum(rendered)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		sum := sha256.Sum256([]byte(`{"kind":"Secret","stringData":{"password":"hunter2"}}{"data":{"level":"debug"},"kind":"ConfigMap"}`))
+		expected := hex.EncodeToString(sum[:])
+
+		annotations := deployment.TemplateData["spec"].(map[string]interface{})["template"].(map[string]interface{})["metadata"].(map[string]interface{})["annotations"].(map[string]interface{})
+		if annotations[types.AVPChecksumAnnotation] != expected {
+			t.Fatalf("expected checksum %s but got %v", expected, annotations[types.AVPChecksumAnnotation])
+		}
+	})
+
+	t.Run("will change the checksum with the secret values", func(t *testing.T) {
+		checksumOf := func(password string) interface{} {
+			changed := checksumTestTemplate("Secret", "app-secret", nil, map[string]interface{}{
+				"stringData": map[string]interface{}{
+					"password": password,
+				},
+			})
+			deployment := checksumTestTemplate("StatefulSet", "app", map[string]string{
+				types.AVPChecksumFromAnnotation: "Secret/app-secret",
+			}, nil)
+
+			err := deployment.InjectChecksum([]*Template{changed})
+			if err != nil {
+				t.Fatal(err)
+			}
+			return nestedMap(deployment.TemplateData, "spec", "template", "metadata", "annotations")[types.AVPChecksumAnnotation]
+		}
+
+		if checksumOf("hunter2") == checksumOf("hunter3") {
+			t.Fatalf("expected the checksum to change with the secret value")
+		}
+	})
+
+	t.Run("will fail for manifests that are not generated", func(t *testing.T) {
+		deployment := checksumTestTemplate("DaemonSet", "app", map[string]string{
+			types.AVPChecksumFromAnnotation: "Secret/other-secret",
+		}, nil)
+
+		err := deployment.InjectChecksum(rendered)
+		expected := `avp.kubernetes.io/checksum-from: could not find Secret/other-secret in namespace "default" among the generated manifests`
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+
+	t.Run("will fail for malformed references", func(t *testing.T) {
+		deployment := checksumTestTemplate("Deployment", "app", map[string]string{
+			types.AVPChecksumFromAnnotation: "app-secret",
+		}, nil)
+
+		err := deployment.InjectChecksum(rendered)
+		expected := "avp.kubernetes.io/checksum-from annotation must be a comma separated list of <kind>/<name>, got app-secret"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+
+	t.Run("will fail for resources without a pod template", func(t *testing.T) {
+		service := checksumTestTemplate("Service", "app", map[string]string{
+			types.AVPChecksumFromAnnotation: "Secret/app-secret",
+		}, nil)
+
+		err := service.InjectChecksum(rendered)
+		expected := "avp.kubernetes.io/checksum-from annotation can only be used on Deployment, StatefulSet or DaemonSet resources"
+		if err == nil || err.Error() != expected {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+}
@@ -0,0 +1,241 @@
+package kube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"regexp"
+	"text/template"
+	"text/template/parse"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+// goTemplateEngine is the value of `avp.kubernetes.io/template-engine` that renders values with text/template
+const goTemplateEngine = "gotemplate"
+
+var goTemplateAction, _ = regexp.Compile(`(?s){{.*}}`)
+
+// usesGoTemplate reports whether the values of a manifest with the given annotations are rendered with text/template,
+// instead of having their <placeholders> replaced
+func usesGoTemplate(annotations map[string]string) bool {
+	return annotations[types.AVPTemplateAnnotation] == goTemplateEngine
+}
+
+// goTemplateReplacement renders value as a text/template, whose `secret` function looks secrets up in the Backend
+func goTemplateReplacement(key, value string, resource Resource) (interface{}, []error) {
+	if !goTemplateAction.MatchString(value) {
+		return value, nil
+	}
+
+	tmpl, parseErr := template.New(key).Option("missingkey=error").Funcs(goTemplateFuncs(key, resource)).Parse(value)
+	if parseErr != nil {
+		return value, []error{fmt.Errorf("could not parse template in string %s: %s", key, parseErr)}
+	}
+
+	var out bytes.Buffer
+	if execErr := tmpl.Execute(&out, nil); execErr != nil {
+		var missingErr *missingKeyError
+		if errors.As(execErr, &missingErr) {
+			return value, []error{missingErr}
+		}
+		return value, []error{fmt.Errorf("could not render template in string %s: %s", key, execErr)}
+	}
+
+	// The rendered value may combine secrets in any way, so all of it is redacted
+	if resource.Redact {
+		return redact(out.String()), nil
+	}
+	return out.String(), nil
+}
+
+// goTemplateFuncs returns the functions available to the templates of resource: `secret` and `hasSecret` to look
+// secrets up, and every modifier, which takes the piped value as its last argument
+func goTemplateFuncs(key string, resource Resource) template.FuncMap {
+	lookup := func(path, secretKey string, version ...string) (interface{}, error) {
+		if len(version) > 1 {
+			return nil, fmt.Errorf("expected a path, a key and an optional version")
+		}
+
+		ref := templateSecretRef{path: path, key: secretKey}
+		if len(version) == 1 {
+			ref.version = version[0]
+		}
+		resolution := ref.resolution(key, resource.Annotations)
+		resolution.Outcome = FailedPlaceholder
+		defer func() {
+			resource.record(resolution)
+		}()
+
+		if resource.PathValidation != nil && !resource.PathValidation.MatchString(path) {
+			return nil, fmt.Errorf("the path %s is disallowed by %s restriction", path, types.EnvPathValidation)
+		}
+
+		utils.VerboseToStdErr("calling GetIndividualSecret for secret %s from path %s at version %s", secretKey, path, resolution.Version)
+		value, err := resource.Backend.GetIndividualSecret(path, secretKey, resolution.Version, resource.Annotations)
+		if err != nil {
+			return nil, err
+		}
+
+		resolution.Outcome = ResolvedPlaceholder
+		if value == nil {
+			resolution.Outcome = MissingPlaceholder
+		}
+		return value, nil
+	}
+
+	funcs := template.FuncMap{
+		"secret": func(path, secretKey string, version ...string) (interface{}, error) {
+			value, err := lookup(path, secretKey, version...)
+			if err == nil && value == nil {
+				return nil, &missingKeyError{
+					s: fmt.Sprintf("replaceString: missing Vault value for key %s at path %s in string %s", secretKey, path, key),
+				}
+			}
+			return value, err
+		},
+		"hasSecret": func(path, secretKey string, version ...string) (bool, error) {
+			value, err := lookup(path, secretKey, version...)
+			return value != nil, err
+		},
+	}
+
+	for name, modifier := range modifiers {
+		modifier := modifier
+		funcs[name] = func(args ...interface{}) (interface{}, error) {
+			if len(args) < 1 {
+				return nil, fmt.Errorf("expected a value")
+			}
+			params := make([]string, len(args)-1)
+			for idx, arg := range args[:len(args)-1] {
+				params[idx] = fmt.Sprint(arg)
+			}
+			return modifier(params, args[len(args)-1])
+		}
+	}
+	return funcs
+}
+
+// templateSecretRef is a `secret` or `hasSecret` call of a template, at the byte offset pos of the template
+type templateSecretRef struct {
+	path    string
+	key     string
+	version string
+	pos     int
+}
+
+// resolution returns the Resolution of the call, in the manifest value key
+func (r templateSecretRef) resolution(key string, annotations map[string]string) Resolution {
+	resolution := Resolution{
+		Key:         key,
+		Placeholder: fmt.Sprintf("secret %q %q", r.path, r.key),
+		Path:        r.path,
+		SecretKey:   r.key,
+		Version:     r.version,
+		Backend:     annotations[types.AVPBackendAnnotation],
+	}
+	if r.version != "" {
+		resolution.Placeholder += fmt.Sprintf(" %q", r.version)
+	}
+	return resolution
+}
+
+// templateSecretRefs returns the `secret` and `hasSecret` calls of the template value, without rendering it.
+// Only calls whose arguments are string literals can be known ahead of time, others are skipped
+func templateSecretRefs(key, value string) ([]templateSecretRef, error) {
+	if !goTemplateAction.MatchString(value) {
+		return nil, nil
+	}
+
+	tmpl, err := template.New(key).Funcs(goTemplateFuncs(key, Resource{})).Parse(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []templateSecretRef
+	for _, t := range tmpl.Templates() {
+		if t.Tree != nil {
+			refs = appendTemplateSecretRefs(refs, t.Tree.Root)
+		}
+	}
+	return refs, nil
+}
+
+// appendTemplateSecretRefs appends the `secret` and `hasSecret` calls with string literal arguments below node to refs
+func appendTemplateSecretRefs(refs []templateSecretRef, node parse.Node) []templateSecretRef {
+	switch n := node.(type) {
+	case *parse.ListNode:
+		if n == nil {
+			return refs
+		}
+		for _, child := range n.Nodes {
+			refs = appendTemplateSecretRefs(refs, child)
+		}
+	case *parse.ActionNode:
+		refs = appendTemplateSecretRefs(refs, n.Pipe)
+	case *parse.IfNode:
+		refs = appendBranchSecretRefs(refs, &n.BranchNode)
+	case *parse.RangeNode:
+		refs = appendBranchSecretRefs(refs, &n.BranchNode)
+	case *parse.WithNode:
+		refs = appendBranchSecretRefs(refs, &n.BranchNode)
+	case *parse.TemplateNode:
+		refs = appendTemplateSecretRefs(refs, n.Pipe)
+	case *parse.PipeNode:
+		if n == nil {
+			return refs
+		}
+		for _, cmd := range n.Cmds {
+			refs = appendTemplateSecretRefs(refs, cmd)
+		}
+	case *parse.CommandNode:
+		if ref, ok := commandSecretRef(n); ok {
+			refs = append(refs, ref)
+		}
+		for _, arg := range n.Args {
+			refs = appendTemplateSecretRefs(refs, arg)
+		}
+	}
+	return refs
+}
+
+func appendBranchSecretRefs(refs []templateSecretRef, n *parse.BranchNode) []templateSecretRef {
+	refs = appendTemplateSecretRefs(refs, n.Pipe)
+	refs = appendTemplateSecretRefs(refs, n.List)
+	return appendTemplateSecretRefs(refs, n.ElseList)
+}
+
+// commandSecretRef returns the secret looked up by a `secret` or `hasSecret` command whose arguments are all string literals
+func commandSecretRef(cmd *parse.CommandNode) (templateSecretRef, bool) {
+	if len(cmd.Args) < 3 || len(cmd.Args) > 4 {
+		return templateSecretRef{}, false
+	}
+	if ident, ok := cmd.Args[0].(*parse.IdentifierNode); !ok || (ident.Ident != "secret" && ident.Ident != "hasSecret") {
+		return templateSecretRef{}, false
+	}
+
+	var args []string
+	for _, arg := range cmd.Args[1:] {
+		str, ok := arg.(*parse.StringNode)
+		if !ok {
+			return templateSecretRef{}, false
+		}
+		args = append(args, str.Text)
+	}
+
+	ref := templateSecretRef{path: args[0], key: args[1], pos: int(cmd.Position())}
+	if len(args) == 3 {
+		ref.version = args[2]
+	}
+	return ref, true
+}
+
+// validateTemplateEngine returns an error if the annotations of a manifest select an unknown template engine
+func validateTemplateEngine(annotations map[string]string) error {
+	engine, ok := annotations[types.AVPTemplateAnnotation]
+	if ok && engine != goTemplateEngine {
+		return fmt.Errorf("unsupported %s %s, only %s is supported", types.AVPTemplateAnnotation, engine, goTemplateEngine)
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package kube
+
+import (
+	"encoding/base64"
+	"regexp"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+)
+
+func TestGoTemplateReplacement(t *testing.T) {
+	mv := helpers.MockVault{}
+	mv.LoadData(map[string]interface{}{
+		"host":     "db-1",
+		"password": "old",
+	})
+	mv.LoadData(map[string]interface{}{
+		"host":     "db",
+		"port":     "5432",
+		"password": "p@ss word",
+		"replicas": []interface{}{"db-r1", "db-r2"},
+	})
+
+	dummyResource := Resource{
+		Kind: "Deployment",
+		TemplateData: map[string]interface{}{
+			"url":      `jdbc:postgresql://{{ secret "kv/data/db" "host" }}:{{ secret "kv/data/db" "port" }}/app?password={{ secret "kv/data/db" "password" | urlencode }}`,
+			"previous": `{{ secret "kv/data/db" "password" "1" | upper }}`,
+			"replicas": `{{ range secret "kv/data/db" "replicas" }}{{ . }};{{ end }}`,
+			"logLevel": `{{ if hasSecret "kv/data/db" "LOG_LEVEL" }}{{ secret "kv/data/db" "LOG_LEVEL" }}{{ else }}info{{ end }}`,
+			"prefixed": `{{ secret "kv/data/db" "host" | prefix "postgres://" | suffix ":5432" }}`,
+			"plain":    "<path:kv/data/db#host>",
+		},
+		Data:    map[string]interface{}{},
+		Backend: &mv,
+		Annotations: map[string]string{
+			(types.AVPTemplateAnnotation): "gotemplate",
+		},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"url":      "jdbc:postgresql://db:5432/app?password=p%40ss+word",
+			"previous": "OLD",
+			"replicas": "db-r1;db-r2;",
+			"logLevel": "info",
+			"prefixed": "postgres://db:5432",
+			"plain":    "<path:kv/data/db#host>",
+		},
+		Data:              map[string]interface{}{},
+		replacementErrors: []error{},
+	}
+
+	assertSuccessfulReplacement(&dummyResource, &expected, t)
+}
+
+func TestGoTemplateReplacement_errors(t *testing.T) {
+	mv := helpers.MockVault{}
+	mv.LoadData(map[string]interface{}{
+		"host": "db",
+	})
+
+	dummyResource := Resource{
+		Kind: "Deployment",
+		TemplateData: map[string]interface{}{
+			"missing":    `{{ secret "kv/data/db" "port" }}`,
+			"disallowed": `{{ secret "secret/db" "host" }}`,
+			"modifier":   `{{ secret "kv/data/db" "host" | jsonParse }}`,
+		},
+		Data:           map[string]interface{}{},
+		Backend:        &mv,
+		PathValidation: regexp.MustCompile(`^kv/`),
+		Annotations: map[string]string{
+			(types.AVPTemplateAnnotation): "gotemplate",
+		},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+	if len(dummyResource.replacementErrors) != 3 {
+		t.Fatalf("expected 3 errors but got: %s", dummyResource.replacementErrors)
+	}
+	expectedErrors := map[string]bool{
+		"replaceString: missing Vault value for key port at path kv/data/db in string missing": true,
+		`could not render template in string disallowed: template: disallowed:1:3: executing "disallowed" at <secret "secret/db" "host">: error calling secret: the path secret/db is disallowed by AVP_PATH_VALIDATION restriction`: true,
+		`could not render template in string modifier: template: modifier:1:32: executing "modifier" at <jsonParse>: error calling jsonParse: invalid character 'd' looking for beginning of value`:                                  true,
+	}
+	for _, err := range dummyResource.replacementErrors {
+		if !expectedErrors[err.Error()] {
+			t.Errorf("unexpected error: %s", err)
+		}
+	}
+	missing := 0
+	for _, err := range dummyResource.replacementErrors {
+		if _, ok := err.(*missingKeyError); ok {
+			missing++
+		}
+	}
+	if missing != 1 {
+		t.Errorf("expected 1 missing key error but got %d", missing)
+	}
+}
+
+func TestGoTemplateReplacement_secret(t *testing.T) {
+	mv := helpers.MockVault{}
+	mv.LoadData(map[string]interface{}{
+		"user":     "admin",
+		"password": "hunter2",
+	})
+
+	config := "{{ with $user := secret \"kv/data/app\" \"user\" }}user={{ $user }}\npassword={{ secret \"kv/data/app\" \"password\" }}{{ end }}"
+	dummyResource := Resource{
+		Kind: "Secret",
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"app.properties": base64.StdEncoding.EncodeToString([]byte(config)),
+			},
+			"stringData": map[string]interface{}{
+				"auth": `{{ secret "kv/data/app" "password" | prefix "admin:" | sha256sum }}`,
+			},
+		},
+		Data:    map[string]interface{}{},
+		Backend: &mv,
+		Annotations: map[string]string{
+			(types.AVPTemplateAnnotation): "gotemplate",
+		},
+		resolutions: &[]Resolution{},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, secretReplacement)
+
+	authSum, _ := sha256sum(nil, "admin:hunter2")
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"app.properties": base64.StdEncoding.EncodeToString([]byte("user=admin\npassword=hunter2")),
+			},
+			"stringData": map[string]interface{}{
+				"auth": authSum,
+			},
+		},
+		Data:              map[string]interface{}{},
+		replacementErrors: []error{},
+	}
+
+	assertSuccessfulReplacement(&dummyResource, &expected, t)
+
+	if len(*dummyResource.resolutions) != 3 {
+		t.Fatalf("expected 3 resolutions but got %v", *dummyResource.resolutions)
+	}
+	for _, resolution := range *dummyResource.resolutions {
+		if resolution.Outcome != ResolvedPlaceholder || resolution.Path != "kv/data/app" {
+			t.Errorf("expected resolved secrets from kv/data/app, got %v", resolution)
+		}
+	}
+}
+
+func TestGoTemplateReplacement_unsupportedEngine(t *testing.T) {
+	d := Template{
+		Resource{
+			Kind: "ConfigMap",
+			Annotations: map[string]string{
+				(types.AVPTemplateAnnotation): "jinja",
+			},
+			TemplateData: map[string]interface{}{},
+		},
+	}
+
+	expectedErr := "unsupported avp.kubernetes.io/template-engine jinja, only gotemplate is supported"
+	err := d.Replace()
+	if err == nil || err.Error() != expectedErr {
+		t.Fatalf("expected error %s but got %v", expectedErr, err)
+	}
+}
@@ -0,0 +1,247 @@
+package kube
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+)
+
+// injectRules are the filters and renames of `avp.kubernetes.io/inject-all`
+type injectRules struct {
+	include []string
+	exclude []string
+	renames map[string]string
+}
+
+// injects reports whether the key of a secret is selected by the include and exclude filters
+func (r injectRules) injects(key string) bool {
+	for _, pattern := range r.exclude {
+		if matched, _ := path.Match(pattern, key); matched {
+			return false
+		}
+	}
+	if len(r.include) == 0 {
+		return true
+	}
+	for _, pattern := range r.include {
+		if matched, _ := path.Match(pattern, key); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// name returns the key a secret key is injected as
+func (r injectRules) name(key string) string {
+	if renamed, ok := r.renames[key]; ok {
+		return renamed
+	}
+	return key
+}
+
+// parseInjectRules reads the comma separated globs of `avp.kubernetes.io/inject-include` and `avp.kubernetes.io/inject-exclude`,
+// and the comma separated `<key>=<new key>` of `avp.kubernetes.io/inject-rename`
+func parseInjectRules(annotations map[string]string) (injectRules, error) {
+	rules := injectRules{
+		renames: make(map[string]string),
+	}
+
+	for _, filter := range []struct {
+		annotation string
+		patterns   *[]string
+	}{
+		{types.AVPInjectIncludeAnnotation, &rules.include},
+		{types.AVPInjectExcludeAnnotation, &rules.exclude},
+	} {
+		for _, pattern := range splitList(annotations[filter.annotation]) {
+			if _, err := path.Match(pattern, ""); err != nil {
+				return rules, fmt.Errorf("invalid pattern %s in %s annotation: %s", pattern, filter.annotation, err)
+			}
+			*filter.patterns = append(*filter.patterns, pattern)
+		}
+	}
+
+	for _, rule := range splitList(annotations[types.AVPInjectRenameAnnotation]) {
+		from, to, ok := strings.Cut(rule, "=")
+		from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+		if !ok || from == "" || to == "" {
+			return rules, fmt.Errorf("invalid rule %s in %s annotation, expected <key>=<new key>", rule, types.AVPInjectRenameAnnotation)
+		}
+		rules.renames[from] = to
+	}
+
+	return rules, nil
+}
+
+// splitList splits a comma separated annotation value, ignoring empty items
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// validateInjectAll returns an error if `avp.kubernetes.io/inject-all` is set on a manifest that it cannot be used on,
+// or with invalid filters or renames
+func validateInjectAll(kind string, annotations map[string]string) error {
+	if injectAll, _ := strconv.ParseBool(annotations[types.AVPInjectAllAnnotation]); !injectAll {
+		return nil
+	}
+
+	if kind != "Secret" && kind != "ConfigMap" {
+		return fmt.Errorf("%s annotation can only be used on Secret or ConfigMap resources, not %s", types.AVPInjectAllAnnotation, kind)
+	}
+	if annotations[types.AVPPathAnnotation] == "" {
+		return fmt.Errorf("%s annotation requires the %s annotation", types.AVPInjectAllAnnotation, types.AVPPathAnnotation)
+	}
+
+	_, err := parseInjectRules(annotations)
+	return err
+}
+
+// injectAll adds every key of the secret at `avp.kubernetes.io/path` to the `data` of a Secret or ConfigMap,
+// or to the `stringData` of Secrets that have one, for manifests with `avp.kubernetes.io/inject-all`.
+// Values are base64 encoded in the `data` of Secrets, and keys already in `data` or `stringData` keep the value
+// written in the manifest
+func injectAll(r *Resource) []error {
+	if injectAll, _ := strconv.ParseBool(r.Annotations[types.AVPInjectAllAnnotation]); !injectAll {
+		return nil
+	}
+	if err := validateInjectAll(r.Kind, r.Annotations); err != nil {
+		return []error{err}
+	}
+	rules, _ := parseInjectRules(r.Annotations)
+
+	field, otherField := injectFields(r.Kind, r.TemplateData)
+
+	target, ok := r.TemplateData[field].(map[string]interface{})
+	if !ok {
+		if r.TemplateData[field] != nil {
+			return []error{fmt.Errorf("%s cannot inject secrets into %s of type %T", types.AVPInjectAllAnnotation, field, r.TemplateData[field])}
+		}
+		target = make(map[string]interface{})
+	}
+	// Kubernetes merges `stringData` over `data`, so a key in either one must not be injected into the other
+	written, _ := r.TemplateData[otherField].(map[string]interface{})
+
+	// Sorted so that conflicts are always reported in the same order
+	keys := make([]string, 0, len(r.Data))
+	for key := range r.Data {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	injected := make(map[string]string)
+	for _, key := range keys {
+		if !rules.injects(key) {
+			continue
+		}
+
+		name := rules.name(key)
+		if other, ok := injected[name]; ok {
+			errs = append(errs, fmt.Errorf("%s: keys %s and %s are both injected as %s", types.AVPInjectAllAnnotation, other, key, name))
+			continue
+		}
+		injected[name] = key
+		_, inTarget := target[name]
+		_, inWritten := written[name]
+		if inTarget || inWritten {
+			utils.VerboseToStdErr("key %s is already in the manifest, not injecting key %s of %s", name, key, r.Annotations[types.AVPPathAnnotation])
+			continue
+		}
+
+		value, err := injectedValue(r.Data[key])
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: could not inject key %s: %s", types.AVPInjectAllAnnotation, key, err))
+			continue
+		}
+
+		switch {
+		case r.Redact:
+			target[name] = redact(value)
+		case r.Kind == "Secret" && field == "data":
+			target[name] = base64.StdEncoding.EncodeToString([]byte(value))
+		default:
+			target[name] = value
+		}
+
+		r.record(Resolution{
+			Key:         name,
+			Placeholder: types.AVPInjectAllAnnotation,
+			Backend:     r.Annotations[types.AVPBackendAnnotation],
+			Path:        r.Annotations[types.AVPPathAnnotation],
+			SecretKey:   key,
+			Version:     r.Annotations[types.AVPSecretVersionAnnotation],
+			Outcome:     ResolvedPlaceholder,
+		})
+	}
+
+	if len(target) > 0 {
+		r.TemplateData[field] = target
+	}
+	return errs
+}
+
+// injectFields returns the field that `avp.kubernetes.io/inject-all` adds keys to, and the other field
+// whose keys it leaves out
+func injectFields(kind string, templateData map[string]interface{}) (string, string) {
+	if _, ok := templateData["stringData"]; ok && kind == "Secret" {
+		return "stringData", "data"
+	}
+	return "data", "stringData"
+}
+
+// injectAllReference returns the reference of a manifest with `avp.kubernetes.io/inject-all` to the keys of its secret,
+// without contacting the Backend. Which keys are injected is only known from the secret, so SecretKey lists the include
+// filters, `*` without any, followed by the exclude filters prefixed with `!`
+func injectAllReference(kind string, templateData map[string]interface{}, annotations map[string]string) (Resolution, bool) {
+	if injectAll, _ := strconv.ParseBool(annotations[types.AVPInjectAllAnnotation]); !injectAll {
+		return Resolution{}, false
+	}
+	if err := validateInjectAll(kind, annotations); err != nil {
+		return Resolution{}, false
+	}
+	rules, _ := parseInjectRules(annotations)
+
+	filters := rules.include
+	if len(filters) == 0 {
+		filters = []string{"*"}
+	}
+	for _, pattern := range rules.exclude {
+		filters = append(filters, "!"+pattern)
+	}
+
+	field, _ := injectFields(kind, templateData)
+	return Resolution{
+		Key:         field,
+		Placeholder: types.AVPInjectAllAnnotation,
+		Backend:     annotations[types.AVPBackendAnnotation],
+		Path:        annotations[types.AVPPathAnnotation],
+		SecretKey:   strings.Join(filters, ","),
+		Version:     annotations[types.AVPSecretVersionAnnotation],
+	}, true
+}
+
+// injectedValue converts a secret value to the string stored in `data`, as JSON for anything but strings
+func injectedValue(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case []byte:
+		return string(v), nil
+	default:
+		data, err := json.Marshal(v)
+		return string(data), err
+	}
+}
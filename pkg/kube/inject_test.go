@@ -0,0 +1,226 @@
+package kube
+
+import (
+	"encoding/base64"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+)
+
+func TestInjectAll(t *testing.T) {
+	secretData := map[string]interface{}{
+		"DB_USER":                 "admin",
+		"DB_PASSWORD":             "hunter2",
+		"DB_PORT":                 5432,
+		"APP_DEBUG":               true,
+		"TLS_KEY":                 "private",
+		"nested":                  map[string]interface{}{"a": "b"},
+		"<path:secret/other#key>": "<path:secret/other#key>",
+	}
+
+	newTemplate := func(kind string, annotations map[string]string, data interface{}) *Template {
+		templateData := map[string]interface{}{
+			"kind": kind,
+		}
+		if data != nil {
+			templateData["data"] = data
+		}
+		return &Template{
+			Resource{
+				Kind:         kind,
+				Annotations:  annotations,
+				TemplateData: templateData,
+				Backend:      &helpers.MockVault{},
+				Data:         secretData,
+				resolutions:  &[]Resolution{},
+			},
+		}
+	}
+
+	t.Run("will inject every key of the path into a ConfigMap", func(t *testing.T) {
+		template := newTemplate("ConfigMap", map[string]string{
+			types.AVPPathAnnotation:      "secret/app",
+			types.AVPInjectAllAnnotation: "true",
+		}, nil)
+
+		if err := template.Replace(); err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := map[string]interface{}{
+			"DB_USER":                 "admin",
+			"DB_PASSWORD":             "hunter2",
+			"DB_PORT":                 "5432",
+			"APP_DEBUG":               "true",
+			"TLS_KEY":                 "private",
+			"nested":                  `{"a":"b"}`,
+			"<path:secret/other#key>": "<path:secret/other#key>",
+		}
+		if !reflect.DeepEqual(template.TemplateData["data"], expected) {
+			t.Fatalf("expected data %v but got %v", expected, template.TemplateData["data"])
+		}
+
+		resolutions := template.Resolutions()
+		if len(resolutions) != len(expected) {
+			t.Fatalf("expected %d resolutions but got %v", len(expected), resolutions)
+		}
+		for _, resolution := range resolutions {
+			if resolution.Placeholder != types.AVPInjectAllAnnotation || resolution.Path != "secret/app" || resolution.Outcome != ResolvedPlaceholder {
+				t.Errorf("expected the injected key to be resolved from secret/app, got %v", resolution)
+			}
+		}
+	})
+
+	t.Run("will filter, rename and base64 encode keys injected into a Secret", func(t *testing.T) {
+		template := newTemplate("Secret", map[string]string{
+			types.AVPPathAnnotation:          "secret/app",
+			types.AVPInjectAllAnnotation:     "true",
+			types.AVPInjectIncludeAnnotation: "DB_*, TLS_*",
+			types.AVPInjectExcludeAnnotation: "*_PORT",
+			types.AVPInjectRenameAnnotation:  "DB_USER=username, DB_PASSWORD=password",
+		}, map[string]interface{}{
+			"username": "<path:secret/app#DB_USER | base64encode>",
+			"TLS_KEY":  base64.StdEncoding.EncodeToString([]byte("override")),
+		})
+		template.Backend = &helpers.MockVault{}
+		template.Backend.(*helpers.MockVault).LoadData(map[string]interface{}{"DB_USER": "root"})
+
+		if err := template.Replace(); err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := map[string]interface{}{
+			"username": base64.StdEncoding.EncodeToString([]byte("root")),
+			"password": base64.StdEncoding.EncodeToString([]byte("hunter2")),
+			"TLS_KEY":  base64.StdEncoding.EncodeToString([]byte("override")),
+		}
+		if !reflect.DeepEqual(template.TemplateData["data"], expected) {
+			t.Fatalf("expected data %v but got %v", expected, template.TemplateData["data"])
+		}
+	})
+
+	t.Run("will inject into the stringData of a Secret", func(t *testing.T) {
+		template := newTemplate("Secret", map[string]string{
+			types.AVPPathAnnotation:          "secret/app",
+			types.AVPInjectAllAnnotation:     "true",
+			types.AVPInjectIncludeAnnotation: "DB_*",
+		}, map[string]interface{}{
+			"DB_USER": base64.StdEncoding.EncodeToString([]byte("override")),
+		})
+		template.TemplateData["stringData"] = map[string]interface{}{
+			"DB_PORT": "6432",
+		}
+
+		if err := template.Replace(); err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expectedData := map[string]interface{}{
+			"DB_USER": base64.StdEncoding.EncodeToString([]byte("override")),
+		}
+		if !reflect.DeepEqual(template.TemplateData["data"], expectedData) {
+			t.Fatalf("expected data %v but got %v", expectedData, template.TemplateData["data"])
+		}
+		expectedStringData := map[string]interface{}{
+			"DB_PASSWORD": "hunter2",
+			"DB_PORT":     "6432",
+		}
+		if !reflect.DeepEqual(template.TemplateData["stringData"], expectedStringData) {
+			t.Fatalf("expected stringData %v but got %v", expectedStringData, template.TemplateData["stringData"])
+		}
+	})
+
+	t.Run("will redact injected values", func(t *testing.T) {
+		template := newTemplate("Secret", map[string]string{
+			types.AVPPathAnnotation:          "secret/app",
+			types.AVPInjectAllAnnotation:     "true",
+			types.AVPInjectIncludeAnnotation: "DB_PASSWORD",
+		}, nil)
+		template.Redact = true
+
+		if err := template.Replace(); err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := map[string]interface{}{
+			"DB_PASSWORD": redact("hunter2"),
+		}
+		if !reflect.DeepEqual(template.TemplateData["data"], expected) {
+			t.Fatalf("expected data %v but got %v", expected, template.TemplateData["data"])
+		}
+	})
+
+	t.Run("will report keys injected with the same name", func(t *testing.T) {
+		template := newTemplate("ConfigMap", map[string]string{
+			types.AVPPathAnnotation:         "secret/app",
+			types.AVPInjectAllAnnotation:    "true",
+			types.AVPInjectRenameAnnotation: "DB_USER=user,DB_PASSWORD=user",
+		}, nil)
+
+		err := template.Replace()
+		expected := "avp.kubernetes.io/inject-all: keys DB_PASSWORD and DB_USER are both injected as user"
+		if err == nil || !strings.Contains(err.Error(), expected) {
+			t.Fatalf("expected error %s but got %v", expected, err)
+		}
+	})
+
+	t.Run("will reject invalid manifests", func(t *testing.T) {
+		tests := []struct {
+			kind        string
+			annotations map[string]string
+			data        interface{}
+			expected    string
+		}{
+			{
+				kind:        "Deployment",
+				annotations: map[string]string{types.AVPPathAnnotation: "secret/app", types.AVPInjectAllAnnotation: "true"},
+				expected:    "avp.kubernetes.io/inject-all annotation can only be used on Secret or ConfigMap resources, not Deployment",
+			},
+			{
+				kind:        "Secret",
+				annotations: map[string]string{types.AVPInjectAllAnnotation: "true"},
+				expected:    "avp.kubernetes.io/inject-all annotation requires the avp.kubernetes.io/path annotation",
+			},
+			{
+				kind:        "Secret",
+				annotations: map[string]string{types.AVPPathAnnotation: "secret/app", types.AVPInjectAllAnnotation: "true", types.AVPInjectIncludeAnnotation: "DB_["},
+				expected:    "invalid pattern DB_[ in avp.kubernetes.io/inject-include annotation: syntax error in pattern",
+			},
+			{
+				kind:        "Secret",
+				annotations: map[string]string{types.AVPPathAnnotation: "secret/app", types.AVPInjectAllAnnotation: "true", types.AVPInjectRenameAnnotation: "DB_USER"},
+				expected:    "invalid rule DB_USER in avp.kubernetes.io/inject-rename annotation, expected <key>=<new key>",
+			},
+			{
+				kind:        "ConfigMap",
+				annotations: map[string]string{types.AVPPathAnnotation: "secret/app", types.AVPInjectAllAnnotation: "true"},
+				data:        "not a map",
+				expected:    "avp.kubernetes.io/inject-all cannot inject secrets into data of type string",
+			},
+		}
+
+		for _, test := range tests {
+			err := newTemplate(test.kind, test.annotations, test.data).Replace()
+			if err == nil || !strings.Contains(err.Error(), test.expected) {
+				t.Errorf("expected error %s but got %v", test.expected, err)
+			}
+		}
+	})
+
+	t.Run("will not inject without the annotation", func(t *testing.T) {
+		template := newTemplate("ConfigMap", map[string]string{
+			types.AVPPathAnnotation:      "secret/app",
+			types.AVPInjectAllAnnotation: "false",
+		}, nil)
+
+		if err := template.Replace(); err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+		if _, ok := template.TemplateData["data"]; ok {
+			t.Fatalf("expected no data but got %v", template.TemplateData["data"])
+		}
+	})
+}
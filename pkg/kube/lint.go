@@ -0,0 +1,377 @@
+package kube
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// inlinePathStart finds the beginning of every inline-path placeholder, well-formed or not
+var inlinePathStart, _ = regexp.Compile(`<(?:\w+:)?path:`)
+
+// strictInlinePath is the complete syntax of an inline-path placeholder, without modifiers
+var strictInlinePath, _ = regexp.Compile(`^(?:\w+:)?path:([^#<>]+)#([^#<>]+)(?:#([^#<>]+))?$`)
+
+// templateAction is a single action of a template, for the modifiers it calls
+var templateAction, _ = regexp.Compile(`(?s){{.*?}}`)
+
+// htpasswdCall is a call to the htpasswd modifier, in a placeholder or template action
+var htpasswdCall, _ = regexp.Compile(`\bhtpasswd\b`)
+
+// identifierPlaceholder is the shape of a generic placeholder, telling them apart from XML tags or e-mail addresses
+var identifierPlaceholder, _ = regexp.Compile(`^[^\s/@=]+$`)
+
+// LintIssue is a problem found in a manifest by Lint. Warnings are reported for manifests that
+// work but likely not as intended, and should not fail the lint
+type LintIssue struct {
+	Line    int
+	Message string
+	Warning bool
+}
+
+func (i LintIssue) String() string {
+	if i.Warning {
+		return fmt.Sprintf("%d: warning: %s", i.Line, i.Message)
+	}
+	return fmt.Sprintf("%d: %s", i.Line, i.Message)
+}
+
+// Lint statically checks the YAML or JSON manifests in data for placeholders and annotations that
+// would fail or be silently skipped by `generate`. It never contacts a Backend
+func Lint(data []byte, pathValidation *regexp.Regexp) ([]LintIssue, error) {
+	var issues []LintIssue
+
+	decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yamlv3.Node
+		err := decoder.Decode(&doc)
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return issues, err
+		}
+
+		// Skip empty manifests
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+			continue
+		}
+		issues = append(issues, lintManifest(doc.Content[0], pathValidation)...)
+	}
+
+	return issues, nil
+}
+
+func lintManifest(manifest *yamlv3.Node, pathValidation *regexp.Regexp) []LintIssue {
+	var issues []LintIssue
+
+	kind := scalarValue(mappingValue(manifest, "kind"))
+	annotations, annotationLines := manifestAnnotations(manifest)
+
+	if path, ok := annotations[types.AVPPathAnnotation]; ok && pathValidation != nil && !pathValidation.MatchString(path) {
+		issues = append(issues, LintIssue{
+			Line:    annotationLines[types.AVPPathAnnotation],
+			Message: fmt.Sprintf("the path %s is disallowed by %s restriction", path, types.EnvPathValidation),
+		})
+	}
+
+	if removeMissing, _ := strconv.ParseBool(annotations[types.AVPRemoveMissingAnnotation]); removeMissing && kind != "Secret" && kind != "ConfigMap" {
+		issues = append(issues, LintIssue{
+			Line:    annotationLines[types.AVPRemoveMissingAnnotation],
+			Message: fmt.Sprintf("%s annotation can only be used on Secret or ConfigMap resources, not %s", types.AVPRemoveMissingAnnotation, kind),
+		})
+	}
+
+	if err := validateInjectAll(kind, annotations); err != nil {
+		issues = append(issues, LintIssue{
+			Line:    annotationLines[types.AVPInjectAllAnnotation],
+			Message: err.Error(),
+		})
+	}
+
+	if avpIgnore, _ := strconv.ParseBool(annotations[types.AVPIgnoreAnnotation]); avpIgnore {
+		return issues
+	}
+
+	walkValues(manifest, kind, annotations, func(value string, line int, encoded bool) {
+		lint := lintValue
+		if usesGoTemplate(annotations) {
+			lint = lintTemplate
+		}
+		for _, issue := range lint(value, annotations, pathValidation) {
+			if encoded {
+				issue.Line = 0
+			}
+			issue.Line += line
+			issues = append(issues, issue)
+		}
+	})
+
+	return issues
+}
+
+// LintChecksumSources warns about the htpasswd modifiers of the manifests named by the `avp.kubernetes.io/checksum-from`
+// annotation of another manifest, across all the inputs keyed by their file name. Their random salt changes the
+// checksum on every run of `generate`, restarting the pods every time. Inputs that cannot be read are skipped
+func LintChecksumSources(inputs map[string][]byte) map[string][]LintIssue {
+	type source struct {
+		file  string
+		ref   string
+		lines []int
+	}
+
+	var sources []source
+	referenced := make(map[string]bool)
+	for file, data := range inputs {
+		decoder := yamlv3.NewDecoder(bytes.NewReader(data))
+		for {
+			var doc yamlv3.Node
+			if err := decoder.Decode(&doc); err != nil {
+				break
+			}
+			if len(doc.Content) == 0 || doc.Content[0].Kind != yamlv3.MappingNode {
+				continue
+			}
+			manifest := doc.Content[0]
+
+			kind := scalarValue(mappingValue(manifest, "kind"))
+			name := scalarValue(mappingValue(mappingValue(manifest, "metadata"), "name"))
+			namespace := scalarValue(mappingValue(mappingValue(manifest, "metadata"), "namespace"))
+			annotations, _ := manifestAnnotations(manifest)
+
+			// References are looked up by kind and name in the namespace of the referencing manifest, like InjectChecksum does
+			for _, ref := range strings.Split(annotations[types.AVPChecksumFromAnnotation], ",") {
+				if refKind, refName, ok := strings.Cut(strings.TrimSpace(ref), "/"); ok {
+					referenced[strings.ToLower(refKind)+"/"+namespace+"/"+refName] = true
+				}
+			}
+
+			if avpIgnore, _ := strconv.ParseBool(annotations[types.AVPIgnoreAnnotation]); avpIgnore {
+				continue
+			}
+			var lines []int
+			walkValues(manifest, kind, annotations, func(value string, line int, encoded bool) {
+				for _, offset := range htpasswdLines(value, annotations) {
+					if encoded {
+						offset = 0
+					}
+					lines = append(lines, line+offset)
+				}
+			})
+			if len(lines) > 0 {
+				sources = append(sources, source{file: file, ref: strings.ToLower(kind) + "/" + namespace + "/" + name, lines: lines})
+			}
+		}
+	}
+
+	issues := make(map[string][]LintIssue)
+	for _, src := range sources {
+		if !referenced[src.ref] {
+			continue
+		}
+		for _, line := range src.lines {
+			issues[src.file] = append(issues[src.file], LintIssue{
+				Line:    line,
+				Message: fmt.Sprintf("htpasswd modifier hashes with a random salt, so the manifest is different on every run and restarts the pods naming it in their %s annotation", types.AVPChecksumFromAnnotation),
+				Warning: true,
+			})
+		}
+	}
+	return issues
+}
+
+// htpasswdLines returns the lines of the htpasswd modifiers in a single string value, counted from its first line
+func htpasswdLines(value string, annotations map[string]string) []int {
+	var lines []int
+	if usesGoTemplate(annotations) {
+		for _, loc := range templateAction.FindAllStringIndex(value, -1) {
+			if htpasswdCall.MatchString(value[loc[0]:loc[1]]) {
+				lines = append(lines, strings.Count(value[:loc[0]], "\n"))
+			}
+		}
+		return lines
+	}
+
+	for _, loc := range placeholderRegexFor(annotations).FindAllStringIndex(value, -1) {
+		_, modifierStmts := splitPlaceholder(value[loc[0]:loc[1]])
+		for _, stmt := range modifierStmts {
+			if fields := strings.Fields(stmt); len(fields) > 0 && fields[0] == "htpasswd" {
+				lines = append(lines, strings.Count(value[:loc[0]], "\n"))
+				break
+			}
+		}
+	}
+	return lines
+}
+
+// lintValue returns the problems with the placeholders in a single string value,
+// with their Line counted from the first line of the value
+func lintValue(value string, annotations map[string]string, pathValidation *regexp.Regexp) []LintIssue {
+	var issues []LintIssue
+	report := func(index int, warning bool, format string, args ...interface{}) {
+		issues = append(issues, LintIssue{
+			Line:    strings.Count(value[:index], "\n"),
+			Message: fmt.Sprintf(format, args...),
+			Warning: warning,
+		})
+	}
+
+	// Inline-path placeholders that the placeholder regexes would skip or misread
+	for _, loc := range inlinePathStart.FindAllStringIndex(value, -1) {
+		end := strings.Index(value[loc[0]:], ">")
+		if end < 0 {
+			report(loc[0], false, "unterminated placeholder %s", value[loc[0]:])
+			continue
+		}
+
+		match := value[loc[0] : loc[0]+end+1]
+		placeholder, _ := splitPlaceholder(match)
+		if !strictInlinePath.MatchString(placeholder) {
+			report(loc[0], false, "malformed placeholder %s, expected <path:some/path#key> or <path:some/path#key#version>", match)
+			continue
+		}
+
+		path, _, _, _ := inlinePath(placeholder)
+		if pathValidation != nil && !pathValidation.MatchString(path) {
+			report(loc[0], false, "the path %s is disallowed by %s restriction", path, types.EnvPathValidation)
+		}
+	}
+
+	_, pathAnnotationPresent := annotations[types.AVPPathAnnotation]
+	for _, loc := range placeholderRegexFor(annotations).FindAllStringIndex(value, -1) {
+		match := value[loc[0]:loc[1]]
+		placeholder, modifierStmts := splitPlaceholder(match)
+		for _, stmt := range modifierStmts {
+			fields := strings.Fields(stmt)
+			if len(fields) == 0 {
+				report(loc[0], false, "empty modifier in placeholder %s", match)
+				continue
+			}
+			if _, ok := modifiers[fields[0]]; !ok {
+				report(loc[0], false, "invalid modifier: %s for placeholder %s", fields[0], placeholder)
+			}
+		}
+	}
+
+	if !pathAnnotationPresent {
+		for _, loc := range genericPlaceholder.FindAllStringIndex(value, -1) {
+			match := value[loc[0]:loc[1]]
+			placeholder, _ := splitPlaceholder(match)
+			// Inline-path placeholders, even malformed ones, are checked above
+			if loc := inlinePathStart.FindStringIndex("<" + placeholder); loc != nil && loc[0] == 0 {
+				continue
+			}
+			// XML tags, e-mail addresses and the like are not placeholders
+			if !identifierPlaceholder.MatchString(placeholder) {
+				continue
+			}
+			report(loc[0], false, "generic placeholder %s will not be replaced because the manifest has no %s annotation", match, types.AVPPathAnnotation)
+		}
+	}
+
+	return issues
+}
+
+// lintTemplate returns the problems with a single template value, with their Line counted from the first line of the value
+func lintTemplate(value string, annotations map[string]string, pathValidation *regexp.Regexp) []LintIssue {
+	calls, err := templateSecretRefs("", value)
+	if err != nil {
+		return []LintIssue{{Message: fmt.Sprintf("could not parse template: %s", err)}}
+	}
+
+	var issues []LintIssue
+	for _, call := range calls {
+		if pathValidation != nil && !pathValidation.MatchString(call.path) {
+			issues = append(issues, LintIssue{
+				Line:    strings.Count(value[:call.pos], "\n"),
+				Message: fmt.Sprintf("the path %s is disallowed by %s restriction", call.path, types.EnvPathValidation),
+			})
+		}
+	}
+	return issues
+}
+
+// manifestAnnotations returns the annotations of a manifest, and the line of each of their values
+func manifestAnnotations(manifest *yamlv3.Node) (map[string]string, map[string]int) {
+	annotations := make(map[string]string)
+	annotationLines := make(map[string]int)
+	if annotationsNode := mappingValue(mappingValue(manifest, "metadata"), "annotations"); annotationsNode != nil && annotationsNode.Kind == yamlv3.MappingNode {
+		for i := 0; i+1 < len(annotationsNode.Content); i += 2 {
+			name := annotationsNode.Content[i].Value
+			annotations[name] = annotationsNode.Content[i+1].Value
+			annotationLines[name] = annotationsNode.Content[i+1].Line
+		}
+	}
+	return annotations, annotationLines
+}
+
+// walkValues calls visit for every string value of a manifest, with the line the value starts on. Like secretReplacement,
+// it looks for placeholders in the decoded form of the base64 values of Secrets, whose lines have no counterpart
+// in the manifest and are visited with encoded set
+func walkValues(manifest *yamlv3.Node, kind string, annotations map[string]string, visit func(value string, line int, encoded bool)) {
+	walkScalars(manifest, func(node *yamlv3.Node) {
+		value := node.Value
+		encoded := false
+
+		if kind == "Secret" {
+			if decoded, ok := decodedSecretValue(value, annotations); ok {
+				value = decoded
+				encoded = true
+			}
+		}
+
+		// The content of block scalars starts on the line after their indicator
+		line := node.Line
+		if node.Style&(yamlv3.LiteralStyle|yamlv3.FoldedStyle) != 0 {
+			line++
+		}
+
+		visit(value, line, encoded)
+	})
+}
+
+// scalarValue returns the value of a YAML scalar node, or "" for nil
+func scalarValue(node *yamlv3.Node) string {
+	if node == nil {
+		return ""
+	}
+	return node.Value
+}
+
+// mappingValue returns the value of key in a YAML mapping node, or nil
+func mappingValue(node *yamlv3.Node, key string) *yamlv3.Node {
+	if node == nil || node.Kind != yamlv3.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// walkScalars calls visit for every string value below node, in document order
+func walkScalars(node *yamlv3.Node, visit func(*yamlv3.Node)) {
+	switch node.Kind {
+	case yamlv3.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			walkScalars(node.Content[i], visit)
+		}
+	case yamlv3.SequenceNode:
+		for _, elm := range node.Content {
+			walkScalars(elm, visit)
+		}
+	case yamlv3.ScalarNode:
+		if node.Tag == "!!str" {
+			visit(node)
+		}
+	}
+}
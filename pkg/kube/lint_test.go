@@ -0,0 +1,227 @@
+package kube
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestLint(t *testing.T) {
+	t.Run("will report issues on the line of each document", func(t *testing.T) {
+		data := []byte(`kind: ConfigMap
+metadata:
+  name: first
+data:
+  key: <path:secret/app#key | base64encode>
+---
+kind: ConfigMap
+metadata:
+  name: second
+data:
+  key: <path:secret/app#key | uppercase>
+  other: <path:#key>
+`)
+
+		issues, err := Lint(data, nil)
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := []LintIssue{
+			{Line: 11, Message: "invalid modifier: uppercase for placeholder path:secret/app#key"},
+			{Line: 12, Message: "malformed placeholder <path:#key>, expected <path:some/path#key> or <path:some/path#key#version>"},
+		}
+		if !reflect.DeepEqual(issues, expected) {
+			t.Fatalf("expected %v but got %v", expected, issues)
+		}
+	})
+
+	t.Run("will validate inline paths", func(t *testing.T) {
+		data := []byte(`{"kind": "ConfigMap", "data": {"key": "<path:../secret#key>"}}`)
+
+		issues, err := Lint(data, regexp.MustCompile(`^([A-Za-z/]*)$`))
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := []LintIssue{
+			{Line: 1, Message: "the path ../secret is disallowed by AVP_PATH_VALIDATION restriction"},
+		}
+		if !reflect.DeepEqual(issues, expected) {
+			t.Fatalf("expected %v but got %v", expected, issues)
+		}
+	})
+
+	t.Run("will validate inject-all", func(t *testing.T) {
+		data := []byte(`kind: Deployment
+metadata:
+  annotations:
+    avp.kubernetes.io/path: secret/app
+    avp.kubernetes.io/inject-all: "true"
+---
+kind: Secret
+metadata:
+  annotations:
+    avp.kubernetes.io/inject-all: "true"
+`)
+
+		issues, err := Lint(data, nil)
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := []LintIssue{
+			{Line: 5, Message: "avp.kubernetes.io/inject-all annotation can only be used on Secret or ConfigMap resources, not Deployment"},
+			{Line: 10, Message: "avp.kubernetes.io/inject-all annotation requires the avp.kubernetes.io/path annotation"},
+		}
+		if !reflect.DeepEqual(issues, expected) {
+			t.Fatalf("expected %v but got %v", expected, issues)
+		}
+	})
+
+	t.Run("will report issues on their line within block scalars", func(t *testing.T) {
+		data := []byte(`kind: ConfigMap
+metadata:
+  annotations:
+    avp.kubernetes.io/path: secret/app
+data:
+  app.properties: |
+    user=<username>
+    password=<password | upcase>
+    url=<path:secret/db>
+`)
+
+		issues, err := Lint(data, nil)
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := []LintIssue{
+			{Line: 9, Message: "malformed placeholder <path:secret/db>, expected <path:some/path#key> or <path:some/path#key#version>"},
+			{Line: 8, Message: "invalid modifier: upcase for placeholder password"},
+		}
+		if !reflect.DeepEqual(issues, expected) {
+			t.Fatalf("expected %v but got %v", expected, issues)
+		}
+	})
+
+	t.Run("will only report identifier-shaped generic placeholders", func(t *testing.T) {
+		data := []byte(`kind: ConfigMap
+data:
+  logback.xml: |
+    <configuration>
+      <appender name="STDOUT" class="ch.qos.logback.core.ConsoleAppender"/>
+      <root level="info"><appender-ref ref="STDOUT"/></root>
+    </configuration>
+  maintainer: Jane Doe <jane@example.com>
+`)
+
+		issues, err := Lint(data, nil)
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := []LintIssue{
+			{Line: 4, Message: "generic placeholder <configuration> will not be replaced because the manifest has no avp.kubernetes.io/path annotation"},
+		}
+		if !reflect.DeepEqual(issues, expected) {
+			t.Fatalf("expected %v but got %v", expected, issues)
+		}
+	})
+
+	t.Run("will check the secret calls of templates", func(t *testing.T) {
+		data := []byte(`kind: ConfigMap
+metadata:
+  annotations:
+    avp.kubernetes.io/template-engine: gotemplate
+data:
+  config: |
+    user={{ secret "kv/data/app" "user" }}
+    password={{ secret "secret/app" "password" }}
+  broken: '{{ secret "kv/data/app" "user" }}{{ end }}'
+  plain: <path:secret/app#ignored>
+`)
+
+		issues, err := Lint(data, regexp.MustCompile(`^kv/`))
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+
+		expected := []LintIssue{
+			{Line: 8, Message: "the path secret/app is disallowed by AVP_PATH_VALIDATION restriction"},
+			{Line: 9, Message: `could not parse template: template: :1: unexpected {{end}}`},
+		}
+		if !reflect.DeepEqual(issues, expected) {
+			t.Fatalf("expected %v but got %v", expected, issues)
+		}
+	})
+
+	t.Run("will skip the values of ignored manifests", func(t *testing.T) {
+		data := []byte(`kind: ConfigMap
+metadata:
+  annotations:
+    avp.kubernetes.io/ignore: "true"
+data:
+  key: <path:secret/app | nothing>
+`)
+
+		issues, err := Lint(data, nil)
+		if err != nil {
+			t.Fatalf("expected no errors but got: %s", err)
+		}
+		if len(issues) != 0 {
+			t.Fatalf("expected no issues but got %v", issues)
+		}
+	})
+}
+
+func TestLintChecksumSources(t *testing.T) {
+	inputs := map[string][]byte{
+		"deployment.yaml": []byte(`kind: Deployment
+metadata:
+  name: app
+  namespace: prod
+  annotations:
+    avp.kubernetes.io/checksum-from: Secret/app-secret, ConfigMap/app-config
+`),
+		"secret.yaml": []byte(`kind: Secret
+metadata:
+  name: app-secret
+  namespace: prod
+  annotations:
+    avp.kubernetes.io/template-engine: gotemplate
+stringData:
+  auth: |
+    {{ secret "kv/data/app" "user" }}
+    {{ secret "kv/data/app" "password" | htpasswd "admin" }}
+---
+kind: Secret
+metadata:
+  name: app-secret
+  namespace: staging
+  annotations:
+    avp.kubernetes.io/path: kv/data/app
+stringData:
+  auth: <password | htpasswd admin>
+`),
+		"configmap.yaml": []byte(`kind: ConfigMap
+metadata:
+  name: app-config
+  namespace: prod
+data:
+  auth: <path:kv/data/app#password | htpasswd admin>
+  plain: <path:kv/data/app#password>
+`),
+	}
+
+	issues := LintChecksumSources(inputs)
+
+	message := "htpasswd modifier hashes with a random salt, so the manifest is different on every run and restarts the pods naming it in their avp.kubernetes.io/checksum-from annotation"
+	expected := map[string][]LintIssue{
+		"secret.yaml":    {{Line: 10, Message: message, Warning: true}},
+		"configmap.yaml": {{Line: 6, Message: message, Warning: true}},
+	}
+	if !reflect.DeepEqual(issues, expected) {
+		t.Fatalf("expected %v but got %v", expected, issues)
+	}
+}
@@ -2,19 +2,27 @@ package kube
 
 import (
 	"bytes"
+	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"net/url"
 	"reflect"
 	"strconv"
 	"strings"
 
+	"golang.org/x/crypto/bcrypt"
 	k8jsonpath "k8s.io/client-go/util/jsonpath"
 	k8yaml "sigs.k8s.io/yaml"
 )
 
+// bcrypt ignores anything past the 72nd byte of a password
+const bcryptMaxPasswordLength = 72
+
 var modifiers = map[string]func([]string, interface{}) (interface{}, error){
 	"base64encode": base64encode,
 	"base64decode": base64decode,
@@ -23,6 +31,32 @@ var modifiers = map[string]func([]string, interface{}) (interface{}, error){
 	"yamlParse":    yamlParse,
 	"indent":       indent,
 	"sha256sum":    sha256sum,
+	"sha1sum":      sha1sum,
+	"sha512sum":    sha512sum,
+	"default":      defaultValue,
+	"upper":        upper,
+	"lower":        lower,
+	"trim":         trim,
+	"replace":      replace,
+	"prefix":       prefix,
+	"suffix":       suffix,
+	"quote":        quote,
+	"urlencode":    urlencode,
+	"hexencode":    hexencode,
+	"base32":       base32encode,
+	"htpasswd":     htpasswd,
+	"toJson":       toJSON,
+	"toYaml":       toYAML,
+	"split":        split,
+	"join":         join,
+}
+
+// defaultValue returns its parameters, joined by spaces, in place of a missing value, and any other value unchanged
+func defaultValue(params []string, input interface{}) (interface{}, error) {
+	if input != nil {
+		return input, nil
+	}
+	return strings.Join(params, " "), nil
 }
 
 func indent(params []string, input interface{}) (interface{}, error) {
@@ -173,3 +207,181 @@ func sha256sum(params []string, input interface{}) (interface{}, error) {
 	}
 
 }
+
+func sha1sum(params []string, input interface{}) (interface{}, error) {
+	if len(params) > 0 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	if reflect.ValueOf(input).Kind() == reflect.String {
+		sum := sha1.Sum([]byte(input.(string)))
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return nil, fmt.Errorf("invalid datatype %v, expected string", reflect.TypeOf(input))
+}
+
+func sha512sum(params []string, input interface{}) (interface{}, error) {
+	if len(params) > 0 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	if reflect.ValueOf(input).Kind() == reflect.String {
+		sum := sha512.Sum512([]byte(input.(string)))
+		return hex.EncodeToString(sum[:]), nil
+	}
+	return nil, fmt.Errorf("invalid datatype %v, expected string", reflect.TypeOf(input))
+}
+
+// stringModifier returns a modifier that takes no parameters and transforms a string with fn
+func stringModifier(fn func(string) string) func([]string, interface{}) (interface{}, error) {
+	return func(params []string, input interface{}) (interface{}, error) {
+		if len(params) > 0 {
+			return nil, fmt.Errorf("invalid parameters")
+		}
+		switch input.(type) {
+		case string:
+			return fn(input.(string)), nil
+		default:
+			return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+		}
+	}
+}
+
+var (
+	upper     = stringModifier(strings.ToUpper)
+	lower     = stringModifier(strings.ToLower)
+	trim      = stringModifier(strings.TrimSpace)
+	quote     = stringModifier(strconv.Quote)
+	urlencode = stringModifier(url.QueryEscape)
+	hexencode = stringModifier(func(s string) string {
+		return hex.EncodeToString([]byte(s))
+	})
+	base32encode = stringModifier(func(s string) string {
+		return base32.StdEncoding.EncodeToString([]byte(s))
+	})
+)
+
+func replace(params []string, input interface{}) (interface{}, error) {
+	// Without a replacement, occurrences are removed
+	if len(params) < 1 || len(params) > 2 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	params = append(params, "")
+
+	switch input.(type) {
+	case string:
+		return strings.ReplaceAll(input.(string), params[0], params[1]), nil
+	default:
+		return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+	}
+}
+
+func prefix(params []string, input interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	switch input.(type) {
+	case string:
+		return strings.Join(params, " ") + input.(string), nil
+	default:
+		return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+	}
+}
+
+func suffix(params []string, input interface{}) (interface{}, error) {
+	if len(params) < 1 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	switch input.(type) {
+	case string:
+		return input.(string) + strings.Join(params, " "), nil
+	default:
+		return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+	}
+}
+
+func htpasswd(params []string, input interface{}) (interface{}, error) {
+	if len(params) != 1 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	switch input.(type) {
+	case string:
+		if len(input.(string)) > bcryptMaxPasswordLength {
+			return nil, fmt.Errorf("passwords longer than %d bytes are not supported by bcrypt", bcryptMaxPasswordLength)
+		}
+		hash, err := bcrypt.GenerateFromPassword([]byte(input.(string)), bcrypt.DefaultCost)
+		if err != nil {
+			return nil, err
+		}
+		return fmt.Sprintf("%s:%s", params[0], hash), nil
+	default:
+		return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+	}
+}
+
+func toJSON(params []string, input interface{}) (interface{}, error) {
+	if len(params) > 0 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	out, err := json.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	return string(out), nil
+}
+
+func toYAML(params []string, input interface{}) (interface{}, error) {
+	if len(params) > 0 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	out, err := k8yaml.Marshal(input)
+	if err != nil {
+		return nil, err
+	}
+	return strings.TrimSuffix(string(out), "\n"), nil
+}
+
+// split turns a string into a list, at commas unless another separator is given
+func split(params []string, input interface{}) (interface{}, error) {
+	if len(params) > 1 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	separator := ","
+	if len(params) == 1 {
+		separator = params[0]
+	}
+
+	switch input.(type) {
+	case string:
+		var list []interface{}
+		for _, item := range strings.Split(input.(string), separator) {
+			list = append(list, item)
+		}
+		return list, nil
+	default:
+		return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+	}
+}
+
+// join turns a list into a string, separated by commas unless another separator is given
+func join(params []string, input interface{}) (interface{}, error) {
+	if len(params) > 1 {
+		return nil, fmt.Errorf("invalid parameters")
+	}
+	separator := ","
+	if len(params) == 1 {
+		separator = params[0]
+	}
+
+	switch input.(type) {
+	case []interface{}:
+		items := make([]string, 0, len(input.([]interface{})))
+		for _, item := range input.([]interface{}) {
+			if reflect.ValueOf(item).Kind() == reflect.Map || reflect.ValueOf(item).Kind() == reflect.Slice {
+				return nil, fmt.Errorf("invalid list item datatype %v", reflect.TypeOf(item))
+			}
+			items = append(items, fmt.Sprint(item))
+		}
+		return strings.Join(items, separator), nil
+	default:
+		return nil, fmt.Errorf("invalid datatype %v", reflect.TypeOf(input))
+	}
+}
@@ -5,7 +5,10 @@ import (
 	"fmt"
 	"os"
 	"reflect"
+	"strings"
 	"testing"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 func assertErrorEqual(t *testing.T, expected error, actual error) {
@@ -245,3 +248,177 @@ func TestSha256Sum_success(t *testing.T) {
 	assertErrorEqual(t, nil, err)
 	assertResultEqual(t, expected, res)
 }
+
+func TestDefaultValue_missing(t *testing.T) {
+	var expected interface{} = "plain text"
+	res, err := defaultValue([]string{"plain", "text"}, nil)
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, expected, res)
+}
+
+func TestDefaultValue_present(t *testing.T) {
+	var data interface{} = json.Number("2")
+	res, err := defaultValue([]string{"3"}, data)
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, data, res)
+}
+
+func TestSha1Sum_invalidParams(t *testing.T) {
+	var data interface{} = "mysecret"
+	expectedErr := fmt.Errorf("invalid parameters")
+	_, err := sha1sum([]string{"astring"}, data)
+	assertErrorEqual(t, expectedErr, err)
+}
+
+func TestSha1Sum_success(t *testing.T) {
+	var data interface{} = "mysecret"
+	var expected interface{} = "e9fe51f94eadabf54dbf2fbbd57188b9abee436e"
+	res, err := sha1sum([]string{}, data)
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, expected, res)
+}
+
+func TestSha512Sum_invalidDataType(t *testing.T) {
+	var data interface{} = 1
+	expectedErr := fmt.Errorf("invalid datatype int, expected string")
+	_, err := sha512sum([]string{}, data)
+	assertErrorEqual(t, expectedErr, err)
+}
+
+func TestSha512Sum_success(t *testing.T) {
+	var data interface{} = "mysecret"
+	var expected interface{} = "7b6f7690ae2a5ecdf66b3db2adf91340a680da1ab82561796b8504db942476967369814aa35050dd86838848c1ba703450f2f5e21b0a8e4cff690b855ae5bd8c"
+	res, err := sha512sum([]string{}, data)
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, expected, res)
+}
+
+func TestStringModifiers_success(t *testing.T) {
+	testCases := []struct {
+		modifier func([]string, interface{}) (interface{}, error)
+		params   []string
+		input    interface{}
+		expected interface{}
+	}{
+		{upper, []string{}, "MySecret", "MYSECRET"},
+		{lower, []string{}, "MySecret", "mysecret"},
+		{trim, []string{}, "  mysecret\n", "mysecret"},
+		{replace, []string{"-", "_"}, "my-se-cret", "my_se_cret"},
+		{replace, []string{"-"}, "my-se-cret", "mysecret"},
+		{prefix, []string{"Bearer"}, "mysecret", "Bearermysecret"},
+		{prefix, []string{"postgres://"}, "db:5432", "postgres://db:5432"},
+		{suffix, []string{":5432"}, "db", "db:5432"},
+		{quote, []string{}, `my "secret"`, `"my \"secret\""`},
+		{urlencode, []string{}, "p@ss word&", "p%40ss+word%26"},
+		{hexencode, []string{}, "mysecret", "6d79736563726574"},
+		{base32encode, []string{}, "mysecret", "NV4XGZLDOJSXI==="},
+	}
+
+	for _, tc := range testCases {
+		res, err := tc.modifier(tc.params, tc.input)
+		assertErrorEqual(t, nil, err)
+		assertResultEqual(t, tc.expected, res)
+	}
+}
+
+func TestStringModifiers_invalid(t *testing.T) {
+	testCases := []struct {
+		modifier    func([]string, interface{}) (interface{}, error)
+		params      []string
+		input       interface{}
+		expectedErr error
+	}{
+		{upper, []string{"astring"}, "mysecret", fmt.Errorf("invalid parameters")},
+		{lower, []string{}, 1, fmt.Errorf("invalid datatype int")},
+		{replace, []string{}, "mysecret", fmt.Errorf("invalid parameters")},
+		{replace, []string{"a", "b", "c"}, "mysecret", fmt.Errorf("invalid parameters")},
+		{replace, []string{"a", "b"}, true, fmt.Errorf("invalid datatype bool")},
+		{prefix, []string{}, "mysecret", fmt.Errorf("invalid parameters")},
+		{suffix, []string{"a"}, []interface{}{}, fmt.Errorf("invalid datatype []interface {}")},
+		{htpasswd, []string{}, "mysecret", fmt.Errorf("invalid parameters")},
+		{htpasswd, []string{"admin"}, 1, fmt.Errorf("invalid datatype int")},
+		{htpasswd, []string{"admin"}, strings.Repeat("a", 73), fmt.Errorf("passwords longer than 72 bytes are not supported by bcrypt")},
+	}
+
+	for _, tc := range testCases {
+		_, err := tc.modifier(tc.params, tc.input)
+		assertErrorEqual(t, tc.expectedErr, err)
+	}
+}
+
+func TestHtpasswd_success(t *testing.T) {
+	res, err := htpasswd([]string{"admin"}, "mysecret")
+	assertErrorEqual(t, nil, err)
+
+	user, hash, _ := strings.Cut(res.(string), ":")
+	if user != "admin" || !strings.HasPrefix(hash, "$2a$10$") {
+		t.Fatalf("expected an htpasswd bcrypt entry for admin, got [%v]", res)
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte("mysecret")); err != nil {
+		t.Fatalf("expected the hash to match the password: %s", err)
+	}
+
+	// Every hash gets a random salt
+	again, _ := htpasswd([]string{"admin"}, "mysecret")
+	if again == res {
+		t.Fatalf("expected a different salt for every hash")
+	}
+}
+
+func TestToJson_success(t *testing.T) {
+	var data interface{} = map[string]interface{}{
+		"user": "admin",
+		"port": json.Number("5432"),
+	}
+	res, err := toJSON([]string{}, data)
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, `{"port":5432,"user":"admin"}`, res)
+}
+
+func TestToYaml_success(t *testing.T) {
+	var data interface{} = map[string]interface{}{
+		"user":  "admin",
+		"hosts": []interface{}{"a", "b"},
+	}
+	res, err := toYAML([]string{}, data)
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, "hosts:\n- a\n- b\nuser: admin", res)
+}
+
+func TestToYaml_invalidParams(t *testing.T) {
+	expectedErr := fmt.Errorf("invalid parameters")
+	_, err := toYAML([]string{"2"}, "mysecret")
+	assertErrorEqual(t, expectedErr, err)
+}
+
+func TestSplitJoin_success(t *testing.T) {
+	res, err := split([]string{}, "a,b,c")
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, []interface{}{"a", "b", "c"}, res)
+
+	res, err = split([]string{";"}, "a;b")
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, []interface{}{"a", "b"}, res)
+
+	res, err = join([]string{}, []interface{}{"a", json.Number("1"), true})
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, "a,1,true", res)
+
+	res, err = join([]string{";"}, []interface{}{"a", "b"})
+	assertErrorEqual(t, nil, err)
+	assertResultEqual(t, "a;b", res)
+}
+
+func TestSplitJoin_invalid(t *testing.T) {
+	_, err := split([]string{",", ";"}, "a,b")
+	assertErrorEqual(t, fmt.Errorf("invalid parameters"), err)
+
+	_, err = split([]string{}, 1)
+	assertErrorEqual(t, fmt.Errorf("invalid datatype int"), err)
+
+	_, err = join([]string{}, "a,b")
+	assertErrorEqual(t, fmt.Errorf("invalid datatype string"), err)
+
+	_, err = join([]string{}, []interface{}{map[string]interface{}{}})
+	assertErrorEqual(t, fmt.Errorf("invalid list item datatype map[string]interface {}"), err)
+}
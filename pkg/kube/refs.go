@@ -0,0 +1,114 @@
+package kube
+
+import (
+	"strconv"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+// SecretRefs returns the lookups that NewTemplate and Replace will make against the Backend for the given manifest,
+// without contacting the Backend. Generic `<placeholder>`s are covered by the `avp.kubernetes.io/path` lookup, and the
+// `secret` and `hasSecret` calls of templates are only known when their arguments are string literals
+func SecretRefs(template unstructured.Unstructured) []types.SecretRef {
+	var refs []types.SecretRef
+
+	annotations := template.GetAnnotations()
+	if path := annotations[types.AVPPathAnnotation]; path != "" {
+		refs = append(refs, types.SecretRef{
+			Path:        path,
+			Version:     annotations[types.AVPSecretVersionAnnotation],
+			Annotations: annotations,
+		})
+	}
+
+	if avpIgnore, _ := strconv.ParseBool(annotations[types.AVPIgnoreAnnotation]); avpIgnore {
+		return refs
+	}
+
+	replaceKeys, _ := strconv.ParseBool(annotations[types.AVPReplaceKeysAnnotation])
+	walkStrings(template.Object, replaceKeys, func(value string) {
+		// Mirror secretReplacement, which looks for placeholders in the decoded form of base64 values
+		if template.GetKind() == "Secret" {
+			if decoded, ok := decodedSecretValue(value, annotations); ok {
+				value = decoded
+			}
+		}
+
+		// Templates only look secrets up with their `secret` and `hasSecret` functions
+		if usesGoTemplate(annotations) {
+			calls, _ := templateSecretRefs("", value)
+			for _, call := range calls {
+				refs = append(refs, types.SecretRef{
+					Path:        call.path,
+					Key:         call.key,
+					Version:     call.version,
+					Annotations: annotations,
+				})
+			}
+			return
+		}
+
+		for _, match := range placeholderRegexFor(annotations).FindAllString(value, -1) {
+			placeholder, _ := splitPlaceholder(match)
+			if path, key, version, ok := inlinePath(placeholder); ok {
+				refs = append(refs, types.SecretRef{
+					Path:        path,
+					Key:         key,
+					Version:     version,
+					Annotations: selectBackend(annotations, placeholder),
+				})
+			}
+		}
+	})
+
+	return refs
+}
+
+// Placeholders returns the secret referred to by each placeholder of the given manifest, without contacting the Backend,
+// followed by the secret of `avp.kubernetes.io/inject-all` if any.
+// Manifests with `avp.kubernetes.io/ignore` have none, as their placeholders are never replaced
+func Placeholders(template unstructured.Unstructured) []Resolution {
+	annotations := template.GetAnnotations()
+	if avpIgnore, _ := strconv.ParseBool(annotations[types.AVPIgnoreAnnotation]); avpIgnore {
+		return nil
+	}
+
+	t := Template{
+		Resource{
+			Kind:         template.GetKind(),
+			TemplateData: template.DeepCopy().Object,
+			Annotations:  annotations,
+			resolutions:  &[]Resolution{},
+		},
+	}
+	replaceInner(&t.Resource, &t.TemplateData, recordingReplacement(""))
+	if reference, ok := injectAllReference(t.Kind, t.TemplateData, annotations); ok {
+		t.record(reference)
+	}
+	return t.Resolutions()
+}
+
+// walkStrings calls visit for every string value that replaceInner would replace placeholders in,
+// and for every key if replaceKeys is set
+func walkStrings(node map[string]interface{}, replaceKeys bool, visit func(string)) {
+	for key, value := range node {
+		if replaceKeys {
+			visit(key)
+		}
+		walkValue(value, replaceKeys, visit)
+	}
+}
+
+func walkValue(value interface{}, replaceKeys bool, visit func(string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		walkStrings(v, replaceKeys, visit)
+	case []interface{}:
+		for _, elm := range v {
+			walkValue(elm, replaceKeys, visit)
+		}
+	case string:
+		visit(v)
+	}
+}
@@ -0,0 +1,288 @@
+package kube
+
+import (
+	"encoding/base64"
+	"reflect"
+	"testing"
+
+	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func TestSecretRefs(t *testing.T) {
+	t.Run("will find the path annotation and inline-path placeholders", func(t *testing.T) {
+		annotations := map[string]interface{}{
+			types.AVPPathAnnotation:          "path/to/secret",
+			types.AVPSecretVersionAnnotation: "2",
+		}
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Deployment",
+				"metadata": map[string]interface{}{
+					"annotations": annotations,
+				},
+				"spec": map[string]interface{}{
+					"replicas": "<replicas>",
+					"containers": []interface{}{
+						map[string]interface{}{
+							"image": "<path:path/to/images#repo | base64encode>:<path:path/to/images#tag#3>",
+						},
+					},
+				},
+			},
+		}
+
+		expectedAnnotations := map[string]string{
+			types.AVPPathAnnotation:          "path/to/secret",
+			types.AVPSecretVersionAnnotation: "2",
+		}
+		expected := []types.SecretRef{
+			{Path: "path/to/secret", Version: "2", Annotations: expectedAnnotations},
+			{Path: "path/to/images", Key: "repo", Annotations: expectedAnnotations},
+			{Path: "path/to/images", Key: "tag", Version: "3", Annotations: expectedAnnotations},
+		}
+
+		refs := SecretRefs(manifest)
+		if !reflect.DeepEqual(refs, expected) {
+			t.Fatalf("expected %v but got %v", expected, refs)
+		}
+	})
+
+	t.Run("will find placeholders in base64 encoded Secret data", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Secret",
+				"data": map[string]interface{}{
+					"password": base64.StdEncoding.EncodeToString([]byte("<path:secret/db#password>")),
+				},
+			},
+		}
+
+		expected := []types.SecretRef{
+			{Path: "secret/db", Key: "password", Annotations: nil},
+		}
+
+		refs := SecretRefs(manifest)
+		if !reflect.DeepEqual(refs, expected) {
+			t.Fatalf("expected %v but got %v", expected, refs)
+		}
+	})
+
+	t.Run("will select the backend named in inline-path placeholders", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"data": map[string]interface{}{
+					"token": "<cloud:path:path/to/token#value>",
+				},
+			},
+		}
+
+		expected := []types.SecretRef{
+			{Path: "path/to/token", Key: "value", Annotations: map[string]string{types.AVPBackendAnnotation: "cloud"}},
+		}
+
+		refs := SecretRefs(manifest)
+		if !reflect.DeepEqual(refs, expected) {
+			t.Fatalf("expected %v but got %v", expected, refs)
+		}
+	})
+
+	t.Run("will find placeholders in nested lists and in keys with replace-keys", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						types.AVPReplaceKeysAnnotation: "true",
+					},
+				},
+				"data": map[string]interface{}{
+					"<path:path/to/names#file>": "value",
+				},
+				"matrix": []interface{}{
+					[]interface{}{"<path:path/to/hosts#primary>"},
+				},
+			},
+		}
+
+		refs := SecretRefs(manifest)
+		if len(refs) != 2 {
+			t.Fatalf("expected the placeholders in the key and the nested list but got %v", refs)
+		}
+		keys := map[string]bool{refs[0].Key: true, refs[1].Key: true}
+		if !keys["file"] || !keys["primary"] {
+			t.Fatalf("expected the placeholders in the key and the nested list but got %v", refs)
+		}
+	})
+
+	t.Run("will only look up the path annotation of ignored manifests", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						types.AVPPathAnnotation:   "path/to/secret",
+						types.AVPIgnoreAnnotation: "true",
+					},
+				},
+				"data": map[string]interface{}{
+					"key": "<path:path/to/other#key>",
+				},
+			},
+		}
+
+		refs := SecretRefs(manifest)
+		if len(refs) != 1 || refs[0].Path != "path/to/secret" || refs[0].Key != "" {
+			t.Fatalf("expected only the path annotation lookup but got %v", refs)
+		}
+	})
+
+	t.Run("will find the secret calls of templates", func(t *testing.T) {
+		annotations := map[string]interface{}{
+			types.AVPTemplateAnnotation: "gotemplate",
+		}
+		config := `{{ secret "kv/data/app" "user" }}:{{ if hasSecret "kv/data/app" "password" "2" }}{{ secret "kv/data/app" "password" "2" | upper }}{{ end }}
+{{ range $key := .keys }}{{ secret "kv/data/app" $key }}{{ end }}<path:kv/data/app#ignored>`
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": annotations,
+				},
+				"data": map[string]interface{}{
+					"config": base64.StdEncoding.EncodeToString([]byte(config)),
+				},
+			},
+		}
+
+		expectedAnnotations := map[string]string{
+			types.AVPTemplateAnnotation: "gotemplate",
+		}
+		expected := []types.SecretRef{
+			{Path: "kv/data/app", Key: "user", Annotations: expectedAnnotations},
+			{Path: "kv/data/app", Key: "password", Version: "2", Annotations: expectedAnnotations},
+			{Path: "kv/data/app", Key: "password", Version: "2", Annotations: expectedAnnotations},
+		}
+
+		refs := SecretRefs(manifest)
+		if !reflect.DeepEqual(refs, expected) {
+			t.Fatalf("expected %v but got %v", expected, refs)
+		}
+	})
+}
+
+func TestPlaceholders(t *testing.T) {
+	t.Run("will describe every placeholder", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Deployment",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						types.AVPPathAnnotation:          "path/to/secret",
+						types.AVPSecretVersionAnnotation: "2",
+					},
+				},
+				"spec": map[string]interface{}{
+					"replicas": "<replicas>",
+					"containers": []interface{}{
+						map[string]interface{}{
+							"image": "<path:path/to/images#repo | base64encode>:<path:path/to/images#tag#3>",
+						},
+					},
+				},
+			},
+		}
+
+		expected := []Resolution{
+			{Key: "image", Placeholder: "path:path/to/images#repo", Path: "path/to/images", SecretKey: "repo", Modifiers: []string{"base64encode"}},
+			{Key: "image", Placeholder: "path:path/to/images#tag#3", Path: "path/to/images", SecretKey: "tag", Version: "3"},
+			{Key: "replicas", Placeholder: "replicas", Path: "path/to/secret", SecretKey: "replicas", Version: "2"},
+		}
+
+		placeholders := Placeholders(manifest)
+		if !reflect.DeepEqual(placeholders, expected) {
+			t.Fatalf("expected %v but got %v", expected, placeholders)
+		}
+		if manifest.Object["spec"].(map[string]interface{})["replicas"] != "<replicas>" {
+			t.Fatalf("expected the manifest to be left untouched")
+		}
+	})
+
+	t.Run("will find the secret calls of templates", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						types.AVPTemplateAnnotation: "gotemplate",
+						types.AVPBackendAnnotation:  "vault",
+					},
+				},
+				"data": map[string]interface{}{
+					"url": `{{ secret "kv/data/db" "host" }}:{{ secret "kv/data/db" "port" "3" }}`,
+				},
+			},
+		}
+
+		expected := []Resolution{
+			{Key: "url", Placeholder: `secret "kv/data/db" "host"`, Path: "kv/data/db", SecretKey: "host", Backend: "vault"},
+			{Key: "url", Placeholder: `secret "kv/data/db" "port" "3"`, Path: "kv/data/db", SecretKey: "port", Version: "3", Backend: "vault"},
+		}
+
+		placeholders := Placeholders(manifest)
+		if !reflect.DeepEqual(placeholders, expected) {
+			t.Fatalf("expected %v but got %v", expected, placeholders)
+		}
+	})
+
+	t.Run("will describe the secret of inject-all with its filters", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						types.AVPPathAnnotation:          "kv/data/app",
+						types.AVPInjectAllAnnotation:     "true",
+						types.AVPInjectIncludeAnnotation: "DB_*,API_KEY",
+						types.AVPInjectExcludeAnnotation: "DB_ADMIN_*",
+					},
+				},
+				"stringData": map[string]interface{}{
+					"URL": "<url>",
+				},
+			},
+		}
+
+		expected := []Resolution{
+			{Key: "URL", Placeholder: "url", Path: "kv/data/app", SecretKey: "url"},
+			{Key: "stringData", Placeholder: types.AVPInjectAllAnnotation, Path: "kv/data/app", SecretKey: "DB_*,API_KEY,!DB_ADMIN_*"},
+		}
+
+		placeholders := Placeholders(manifest)
+		if !reflect.DeepEqual(placeholders, expected) {
+			t.Fatalf("expected %v but got %v", expected, placeholders)
+		}
+	})
+
+	t.Run("will skip manifests with avp.kubernetes.io/ignore", func(t *testing.T) {
+		manifest := unstructured.Unstructured{
+			Object: map[string]interface{}{
+				"kind": "Secret",
+				"metadata": map[string]interface{}{
+					"annotations": map[string]interface{}{
+						types.AVPIgnoreAnnotation: "true",
+					},
+				},
+				"stringData": map[string]interface{}{
+					"password": "<path:secret/db#password>",
+				},
+			},
+		}
+
+		placeholders := Placeholders(manifest)
+		if len(placeholders) != 0 {
+			t.Fatalf("expected no placeholders but got %v", placeholders)
+		}
+	})
+}
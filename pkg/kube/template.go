@@ -3,6 +3,7 @@ package kube
 import (
 	"fmt"
 	"regexp"
+	"sort"
 	"strings"
 
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/types"
@@ -11,6 +12,28 @@ import (
 	yaml "sigs.k8s.io/yaml"
 )
 
+// Outcomes of replacing a placeholder
+const (
+	ResolvedPlaceholder  = "resolved"
+	DefaultedPlaceholder = "defaulted"
+	MissingPlaceholder   = "missing"
+	RemovedPlaceholder   = "removed"
+	FailedPlaceholder    = "error"
+	IgnoredPlaceholder   = "ignored"
+)
+
+// A Resolution describes how a single <placeholder> was replaced. It never holds the secret value
+type Resolution struct {
+	Key         string   `json:"key"`                 // The key of the value containing the placeholder
+	Placeholder string   `json:"placeholder"`         // The placeholder as written in the template, without modifiers
+	Backend     string   `json:"backend,omitempty"`   // The named backend selected for the secret, empty for the default
+	Path        string   `json:"path"`                // The path of the secret, from the placeholder or `avp.kubernetes.io/path`
+	SecretKey   string   `json:"secretKey"`           // The key of the secret at Path
+	Version     string   `json:"version,omitempty"`   // The version of the secret, empty for the latest
+	Modifiers   []string `json:"modifiers,omitempty"` // The modifier statements applied to the secret value
+	Outcome     string   `json:"outcome"`
+}
+
 // A Resource is the basis for all Templates
 type Resource struct {
 	Kind              string
@@ -20,6 +43,8 @@ type Resource struct {
 	Data              map[string]interface{} // The data to replace with, from Vault
 	Annotations       map[string]string
 	PathValidation    *regexp.Regexp
+	Redact            bool          // Replace placeholders with a checksum of the secret value instead of the value
+	resolutions       *[]Resolution // How each placeholder was replaced, shared by the copies handed to replacer functions
 }
 
 // Template is the template for Kubernetes
@@ -55,16 +80,40 @@ func NewTemplate(template unstructured.Unstructured, backend types.Backend, path
 			Data:           data,
 			Annotations:    annotations,
 			PathValidation: pathValidation,
+			resolutions:    &[]Resolution{},
 		},
 	}, nil
 }
 
+// Resolutions returns how each placeholder was handled by Replace or Skip, ordered by key
+func (t *Template) Resolutions() []Resolution {
+	if t.resolutions == nil {
+		return nil
+	}
+
+	// Placeholders are replaced in no particular order
+	sort.SliceStable(*t.resolutions, func(i, j int) bool {
+		return (*t.resolutions)[i].Key < (*t.resolutions)[j].Key
+	})
+	return *t.resolutions
+}
+
+// Skip leaves the Template's data untouched, as for manifests with `avp.kubernetes.io/ignore`,
+// but records its placeholders as ignored
+func (t *Template) Skip() {
+	replaceInner(&t.Resource, &t.TemplateData, recordingReplacement(IgnoredPlaceholder))
+}
+
 // Replace will replace the <placeholders> in the Template's data with values from Vault.
 // It will return an aggregrate of any errors encountered during the replacements.
 // For both non-Secret resources and Secrets with <placeholder>'s in `stringData`, the value in Vault is emitted as-is
 // For Secret's with <placeholder>'s in `.data`, the value in Vault is emitted as base64
 // For any hard-coded strings that aren't <placeholder>'s, the string is emitted as-is
 func (t *Template) Replace() error {
+	if err := validateTemplateEngine(t.Annotations); err != nil {
+		return err
+	}
+
 	var replacerFunc func(string, string, Resource) (interface{}, []error)
 
 	switch t.Kind {
@@ -77,6 +126,9 @@ func (t *Template) Replace() error {
 	}
 
 	replaceInner(&t.Resource, &t.TemplateData, replacerFunc)
+
+	// Injected after replacement, so that secret values are never mistaken for placeholders
+	t.replacementErrors = append(t.replacementErrors, injectAll(&t.Resource)...)
 	if len(t.replacementErrors) != 0 {
 		errMessages := make([]string, len(t.replacementErrors))
 		for idx, err := range t.replacementErrors {
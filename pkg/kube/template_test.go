@@ -415,7 +415,7 @@ func TestNewTemplate(t *testing.T) {
 		}, &mv, regexp.MustCompile(`/[A-Z]/`))
 
 		if template != nil {
-			t.Fatalf("expected template to be nil got %s", template)
+			t.Fatalf("expected template to be nil got %v", template)
 		}
 		if err == nil {
 			t.Fatalf("expected error got nil")
@@ -1128,3 +1128,143 @@ func TestToYAML_Job(t *testing.T) {
 		t.Fatalf("expected YAML:\n%s\nbut got:\n%s\n", expected, actual)
 	}
 }
+
+func TestToYAML_Redact(t *testing.T) {
+	mv := helpers.MockVault{}
+	mv.LoadData(map[string]interface{}{
+		"password": "hunter2",
+	})
+
+	d := Template{
+		Resource{
+			Kind: "Secret",
+			Annotations: map[string]string{
+				types.AVPPathAnnotation:          "path/to/secret",
+				types.AVPRemoveMissingAnnotation: "true",
+			},
+			TemplateData: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata": map[string]interface{}{
+					"namespace": "default",
+					"name":      "my-app",
+				},
+				"stringData": map[string]interface{}{
+					"MY_SECRET_STRING": "<string>",
+					"MY_SECRET_NUM":    "<num | jsonPath {.value}>",
+					"MY_PASSWORD":      "<path:path/to/other#password#1>",
+				},
+			},
+			Backend: &mv,
+			Data: map[string]interface{}{
+				"num": map[string]interface{}{"value": 5},
+			},
+			Redact:      true,
+			resolutions: &[]Resolution{},
+		},
+	}
+
+	err := d.Replace()
+	if err != nil {
+		t.Fatalf(err.Error())
+	}
+
+	expectedData := map[string]interface{}{
+		"MY_SECRET_NUM": "***sha256:ef2d127d***",
+		"MY_PASSWORD":   "***sha256:f52fbd32***",
+	}
+	if !reflect.DeepEqual(d.TemplateData["stringData"], expectedData) {
+		t.Fatalf("expected %v but got %v", expectedData, d.TemplateData["stringData"])
+	}
+
+	expectedResolutions := map[string]Resolution{
+		"MY_SECRET_STRING": {
+			Key:         "MY_SECRET_STRING",
+			Placeholder: "string",
+			Path:        "path/to/secret",
+			SecretKey:   "string",
+			Outcome:     RemovedPlaceholder,
+		},
+		"MY_SECRET_NUM": {
+			Key:         "MY_SECRET_NUM",
+			Placeholder: "num",
+			Path:        "path/to/secret",
+			SecretKey:   "num",
+			Modifiers:   []string{"jsonPath {.value}"},
+			Outcome:     ResolvedPlaceholder,
+		},
+		"MY_PASSWORD": {
+			Key:         "MY_PASSWORD",
+			Placeholder: "path:path/to/other#password#1",
+			Path:        "path/to/other",
+			SecretKey:   "password",
+			Version:     "1",
+			Outcome:     ResolvedPlaceholder,
+		},
+	}
+	resolutions := d.Resolutions()
+	if len(resolutions) != len(expectedResolutions) {
+		t.Fatalf("expected %d resolutions but got %v", len(expectedResolutions), resolutions)
+	}
+	for _, resolution := range resolutions {
+		if !reflect.DeepEqual(resolution, expectedResolutions[resolution.Key]) {
+			t.Fatalf("expected %v but got %v", expectedResolutions[resolution.Key], resolution)
+		}
+	}
+}
+
+func TestSkip(t *testing.T) {
+	d := Template{
+		Resource{
+			Kind: "Secret",
+			Annotations: map[string]string{
+				types.AVPPathAnnotation:   "path/to/secret",
+				types.AVPIgnoreAnnotation: "true",
+			},
+			TemplateData: map[string]interface{}{
+				"apiVersion": "v1",
+				"kind":       "Secret",
+				"metadata": map[string]interface{}{
+					"namespace": "default",
+					"name":      "my-app",
+				},
+				"data": map[string]interface{}{
+					"MY_SECRET_STRING": "PHN0cmluZz4=",
+					"MY_SECRET_NUM":    "<path:path/to/other#num | base64encode>",
+				},
+			},
+			resolutions: &[]Resolution{},
+		},
+	}
+
+	d.Skip()
+
+	expectedData := map[string]interface{}{
+		"MY_SECRET_STRING": "PHN0cmluZz4=",
+		"MY_SECRET_NUM":    "<path:path/to/other#num | base64encode>",
+	}
+	if !reflect.DeepEqual(d.TemplateData["data"], expectedData) {
+		t.Fatalf("expected %v but got %v", expectedData, d.TemplateData["data"])
+	}
+
+	expected := []Resolution{
+		{
+			Key:         "MY_SECRET_NUM",
+			Placeholder: "path:path/to/other#num",
+			Path:        "path/to/other",
+			SecretKey:   "num",
+			Modifiers:   []string{"base64encode"},
+			Outcome:     IgnoredPlaceholder,
+		},
+		{
+			Key:         "MY_SECRET_STRING",
+			Placeholder: "string",
+			Path:        "path/to/secret",
+			SecretKey:   "string",
+			Outcome:     IgnoredPlaceholder,
+		},
+	}
+	if !reflect.DeepEqual(d.Resolutions(), expected) {
+		t.Fatalf("expected %v but got %v", expected, d.Resolutions())
+	}
+}
@@ -2,11 +2,14 @@ package kube
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"reflect"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
 
@@ -24,8 +27,8 @@ func (e *missingKeyError) Error() string {
 }
 
 var genericPlaceholder, _ = regexp.Compile(`(?mU)<(.*)>`)
-var specificPathPlaceholder, _ = regexp.Compile(`(?mU)<path:([^#]+)#([^#]+)(?:#([^#]+))?>`)
-var indivPlaceholderSyntax, _ = regexp.Compile(`(?mU)path:(?P<path>[^#]+?)#(?P<key>[^#]+?)(?:#(?P<version>.+?))??`)
+var specificPathPlaceholder, _ = regexp.Compile(`(?mU)<(?:\w+:)?path:([^#]+)#([^#]+)(?:#([^#]+))?>`)
+var indivPlaceholderSyntax, _ = regexp.Compile(`(?mU)(?:(?P<backend>\w+):)?path:(?P<path>[^#]+?)#(?P<key>[^#]+?)(?:#(?P<version>.+?))??`)
 
 // replaceInner recurses through the given map and replaces the placeholders by calling `replacerFunc`
 // with the key, value, and map of keys to replacement values
@@ -52,94 +55,252 @@ func replaceInner(
 			}
 			replaceInner(r, &inner, replacerFunc)
 		} else if valueType == reflect.Slice {
-			for idx, elm := range value.([]interface{}) {
-				switch elm.(type) {
-				case map[string]interface{}:
-					{
-						inner := elm.(map[string]interface{})
-						replaceInner(r, &inner, replacerFunc)
-					}
-				case string:
-					{
-						// Base case, replace templated strings
-						replacement, err := replacerFunc(key, elm.(string), *r)
-						if len(err) != 0 {
-							r.replacementErrors = append(r.replacementErrors, err...)
-						}
-						value.([]interface{})[idx] = replacement
-					}
-				}
+			inner, ok := value.([]interface{})
+			if !ok {
+				continue
 			}
+			replaceSlice(r, key, inner, replacerFunc)
 		} else if valueType == reflect.String {
 
 			// Base case, replace templated strings
-			removeKey := false
+			recorded := r.resolutionCount()
 			replacement, err := replacerFunc(key, value.(string), *r)
-			if len(err) != 0 {
-				if removeMissing {
-					var filteredErr []error
-					for _, e := range err {
-						if _, ok := e.(*missingKeyError); ok {
-							removeKey = true
-						} else {
-							filteredErr = append(filteredErr, e)
-						}
-					}
-
-					err = filteredErr
-				}
-
-				r.replacementErrors = append(r.replacementErrors, err...)
-			}
+			err, removeKey := filterMissing(err, removeMissing)
+			r.replacementErrors = append(r.replacementErrors, err...)
 
 			if removeKey {
 				utils.VerboseToStdErr("removing key %s due to %s being set on the containing manifest", key, types.AVPRemoveMissingAnnotation)
 				delete(obj, key)
+				r.markRemoved(recorded)
 			} else {
 				obj[key] = replacement
 			}
 		}
 	}
+
+	if replaceKeys, _ := strconv.ParseBool(r.Annotations[types.AVPReplaceKeysAnnotation]); replaceKeys {
+		replaceKeysInner(r, obj, replacerFunc, removeMissing)
+	}
 }
 
-func genericReplacement(key, value string, resource Resource) (_ interface{}, err []error) {
-	var nonStringReplacement interface{}
-	var placeholderRegex = specificPathPlaceholder
+// replaceSlice replaces the placeholders in the elements of a list, recursing through nested maps and lists
+func replaceSlice(
+	r *Resource,
+	key string,
+	slice []interface{},
+	replacerFunc func(string, string, Resource) (interface{}, []error)) {
+	for idx, elm := range slice {
+		switch elm.(type) {
+		case map[string]interface{}:
+			{
+				inner := elm.(map[string]interface{})
+				replaceInner(r, &inner, replacerFunc)
+			}
+		case []interface{}:
+			{
+				replaceSlice(r, key, elm.([]interface{}), replacerFunc)
+			}
+		case string:
+			{
+				// Base case, replace templated strings
+				replacement, err := replacerFunc(key, elm.(string), *r)
+				if len(err) != 0 {
+					r.replacementErrors = append(r.replacementErrors, err...)
+				}
+				slice[idx] = replacement
+			}
+		}
+	}
+}
+
+// replaceKeysInner replaces the placeholders in the keys of the given map, for manifests with `avp.kubernetes.io/replace-keys`
+// Keys are only renamed if no two keys of the map end up with the same name
+func replaceKeysInner(
+	r *Resource,
+	obj map[string]interface{},
+	replacerFunc func(string, string, Resource) (interface{}, []error),
+	removeMissing bool) {
+	renamed := make(map[string]string)
+	for key := range obj {
+		recorded := r.resolutionCount()
+		replacement, err := replacerFunc(key, key, *r)
+		err, removeKey := filterMissing(err, removeMissing)
+		if len(err) != 0 {
+			r.replacementErrors = append(r.replacementErrors, err...)
+			continue
+		}
+
+		if removeKey {
+			utils.VerboseToStdErr("removing key %s due to %s being set on the containing manifest", key, types.AVPRemoveMissingAnnotation)
+			delete(obj, key)
+			r.markRemoved(recorded)
+			continue
+		}
+
+		newKey, ok := scalarString(replacement)
+		if !ok {
+			r.replacementErrors = append(r.replacementErrors, fmt.Errorf("replaceKey: placeholders in key %s must be replaced with a string, got %T", key, replacement))
+			continue
+		}
+		if newKey != key {
+			renamed[key] = newKey
+		}
+	}
 
+	// Sorted so that collisions are always reported in the same order
+	oldKeys := make([]string, 0, len(renamed))
+	for key := range renamed {
+		oldKeys = append(oldKeys, key)
+	}
+	sort.Strings(oldKeys)
+
+	claimed := make(map[string]string)
+	for _, key := range oldKeys {
+		newKey := renamed[key]
+		if _, exists := obj[newKey]; exists {
+			if _, movedAway := renamed[newKey]; !movedAway {
+				r.replacementErrors = append(r.replacementErrors, fmt.Errorf("replaceKey: key %s is replaced with %s, which collides with an existing key", key, newKey))
+				delete(renamed, key)
+				continue
+			}
+		}
+		if other, taken := claimed[newKey]; taken {
+			r.replacementErrors = append(r.replacementErrors, fmt.Errorf("replaceKey: keys %s and %s are both replaced with %s", other, key, newKey))
+			delete(renamed, key)
+			continue
+		}
+		claimed[newKey] = key
+	}
+
+	values := make(map[string]interface{}, len(renamed))
+	for key := range renamed {
+		values[key] = obj[key]
+		delete(obj, key)
+	}
+	for key, newKey := range renamed {
+		obj[newKey] = values[key]
+	}
+}
+
+// filterMissing separates the errors of missing keys from the others when `avp.kubernetes.io/remove-missing` is set,
+// returning the other errors and whether any key was missing
+func filterMissing(err []error, removeMissing bool) ([]error, bool) {
+	if !removeMissing {
+		return err, false
+	}
+
+	missing := false
+	var filteredErr []error
+	for _, e := range err {
+		if _, ok := e.(*missingKeyError); ok {
+			missing = true
+		} else {
+			filteredErr = append(filteredErr, e)
+		}
+	}
+	return filteredErr, missing
+}
+
+// scalarString converts a replacement to a string, if it is a scalar
+func scalarString(replacement interface{}) (string, bool) {
+	switch replacement.(type) {
+	case string, int, bool, json.Number, []byte:
+		return stringify(replacement), true
+	default:
+		return "", false
+	}
+}
+
+// placeholderRegexFor returns the regex matching the placeholders of a manifest with the given annotations
+func placeholderRegexFor(annotations map[string]string) *regexp.Regexp {
 	// If the Vault path annotation is present, there may be placeholders with/without an explicit path
 	// so we look for those. Only if the annotation is absent do we narrow the search to placeholders with
 	// explicit paths, to prevent catching <things> that aren't placeholders
 	// See https://github.com/argoproj-labs/argocd-vault-plugin/issues/130
-	if _, pathAnnotationPresent := resource.Annotations[types.AVPPathAnnotation]; pathAnnotationPresent {
-		placeholderRegex = genericPlaceholder
+	if _, pathAnnotationPresent := annotations[types.AVPPathAnnotation]; pathAnnotationPresent {
+		return genericPlaceholder
+	}
+	return specificPathPlaceholder
+}
+
+// splitPlaceholder separates a matched `<placeholder | modifier args>` into the placeholder and its modifier statements
+func splitPlaceholder(match string) (string, []string) {
+	placeholder := strings.Trim(match, "<>")
+
+	// Split modifiers from placeholder
+	pipelineFields := strings.Split(placeholder, "|")
+	return strings.Trim(pipelineFields[0], " "), pipelineFields[1:]
+}
+
+// inlinePath returns the path, key and version of an inline-path placeholder,
+// or ok=false if the placeholder refers to a key from the `avp.kubernetes.io/path` secret
+func inlinePath(placeholder string) (path, key, version string, ok bool) {
+	if !indivPlaceholderSyntax.Match([]byte(placeholder)) {
+		return "", "", "", false
+	}
+
+	indivSecretMatches := indivPlaceholderSyntax.FindStringSubmatch(placeholder)
+	path = indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("path")]
+	key = indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("key")]
+	version = indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("version")]
+	return path, strings.TrimSpace(key), version, true
+}
+
+// placeholderBackend returns the name of the backend selected by an inline-path placeholder like `<name:path:...>`, if any
+func placeholderBackend(placeholder string) string {
+	indivSecretMatches := indivPlaceholderSyntax.FindStringSubmatch(placeholder)
+	if indivSecretMatches == nil {
+		return ""
+	}
+	return indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("backend")]
+}
+
+// selectBackend returns the annotations to look the placeholder up with, selecting the backend named in it if any
+func selectBackend(annotations map[string]string, placeholder string) map[string]string {
+	name := placeholderBackend(placeholder)
+	if name == "" {
+		return annotations
+	}
+
+	selected := make(map[string]string, len(annotations)+1)
+	for k, v := range annotations {
+		selected[k] = v
 	}
+	selected[types.AVPBackendAnnotation] = name
+	return selected
+}
 
-	res := placeholderRegex.ReplaceAllFunc([]byte(value), func(match []byte) []byte {
-		placeholder := strings.Trim(string(match), "<>")
+func genericReplacement(key, value string, resource Resource) (_ interface{}, err []error) {
+	if usesGoTemplate(resource.Annotations) {
+		return goTemplateReplacement(key, value, resource)
+	}
+
+	var nonStringReplacement interface{}
 
-		// Split modifiers from placeholder
-		pipelineFields := strings.Split(placeholder, "|")
-		placeholder = strings.Trim(pipelineFields[0], " ")
+	res := placeholderRegexFor(resource.Annotations).ReplaceAllFunc([]byte(value), func(match []byte) []byte {
+		placeholder, modifierStmts := splitPlaceholder(string(match))
 
-		utils.VerboseToStdErr("found placeholder %s with modifiers %s", placeholder, pipelineFields[1:])
+		utils.VerboseToStdErr("found placeholder %s with modifiers %s", placeholder, modifierStmts)
+
+		// Assume failure until the placeholder is actually replaced
+		resolution := newResolution(key, placeholder, modifierStmts, resource.Annotations)
+		resolution.Outcome = FailedPlaceholder
+		defer func() {
+			resource.record(resolution)
+		}()
 
 		var secretValue interface{}
 		var secretErr error
 		// Check to see if should call out to get individual secret (inline-path in placeholder)
 		// This can include an optional version argument - if unspecified, the latest version is retrieved
-		if indivPlaceholderSyntax.Match([]byte(placeholder)) {
-			indivSecretMatches := indivPlaceholderSyntax.FindStringSubmatch(placeholder)
-			path := indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("path")]
-			key := indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("key")]
-			version := indivSecretMatches[indivPlaceholderSyntax.SubexpIndex("version")]
-
+		if path, key, version, ok := inlinePath(placeholder); ok {
 			if resource.PathValidation != nil && !resource.PathValidation.MatchString(path) {
 				err = append(err, fmt.Errorf("the path %s is disallowed by %s restriction", path, types.EnvPathValidation))
 				return match
 			}
 
 			utils.VerboseToStdErr("calling GetIndividualSecret for secret %s from path %s at version %s", key, path, version)
-			secretValue, secretErr = resource.Backend.GetIndividualSecret(path, strings.TrimSpace(key), version, resource.Annotations)
+			secretValue, secretErr = resource.Backend.GetIndividualSecret(path, key, version, selectBackend(resource.Annotations, placeholder))
 			if secretErr != nil {
 				err = append(err, secretErr)
 				return match
@@ -148,26 +309,40 @@ func genericReplacement(key, value string, resource Resource) (_ interface{}, er
 			secretValue = resource.Data[placeholder]
 		}
 
-		if secretValue != nil {
-			// Process modifiers
-			for _, stmt := range pipelineFields[1:] {
-				fields := strings.Fields(stmt)
-				functionName := strings.Trim(fields[0], " ")
+		// Process modifiers, only `default` applies to a missing value
+		defaulted := false
+		for _, stmt := range modifierStmts {
+			fields := strings.Fields(stmt)
+			functionName := strings.Trim(fields[0], " ")
 
-				utils.VerboseToStdErr("processing modifier %s with args %q", functionName, fields)
+			utils.VerboseToStdErr("processing modifier %s with args %q", functionName, fields)
 
-				if _, ok := modifiers[functionName]; !ok {
-					e := fmt.Errorf("invalid modifier: %s for placeholder %s in string %s: %s", functionName, placeholder, key, value)
-					err = append(err, e)
-					return match
-				}
-				var modErr error
-				secretValue, modErr = modifiers[functionName](fields[1:], secretValue)
-				if modErr != nil {
-					e := fmt.Errorf("%s: %s for placeholder %s in string %s: %s", functionName, modErr.Error(), placeholder, key, value)
-					err = append(err, e)
-					return match
-				}
+			if _, ok := modifiers[functionName]; !ok {
+				e := fmt.Errorf("invalid modifier: %s for placeholder %s in string %s: %s", functionName, placeholder, key, value)
+				err = append(err, e)
+				return match
+			}
+			if secretValue == nil && functionName != "default" {
+				continue
+			}
+			defaulted = defaulted || secretValue == nil
+
+			var modErr error
+			secretValue, modErr = modifiers[functionName](fields[1:], secretValue)
+			if modErr != nil {
+				e := fmt.Errorf("%s: %s for placeholder %s in string %s: %s", functionName, modErr.Error(), placeholder, key, value)
+				err = append(err, e)
+				return match
+			}
+		}
+
+		if secretValue != nil {
+			resolution.Outcome = ResolvedPlaceholder
+			if defaulted {
+				resolution.Outcome = DefaultedPlaceholder
+			}
+			if resource.Redact {
+				secretValue = redact(secretValue)
 			}
 
 			switch secretValue.(type) {
@@ -186,6 +361,7 @@ func genericReplacement(key, value string, resource Resource) (_ interface{}, er
 				s: fmt.Sprintf("replaceString: missing Vault value for placeholder %s in string %s: %s", placeholder, key, value),
 			}
 			err = append(err, missingKeyErr)
+			resolution.Outcome = MissingPlaceholder
 		}
 
 		return match
@@ -202,6 +378,96 @@ func genericReplacement(key, value string, resource Resource) (_ interface{}, er
 	return string(res), err
 }
 
+// newResolution describes the secret a placeholder refers to, leaving its Outcome to the caller
+func newResolution(key, placeholder string, modifierStmts []string, annotations map[string]string) Resolution {
+	resolution := Resolution{
+		Key:         key,
+		Placeholder: placeholder,
+		Path:        annotations[types.AVPPathAnnotation],
+		SecretKey:   placeholder,
+		Version:     annotations[types.AVPSecretVersionAnnotation],
+	}
+	if path, secretKey, version, ok := inlinePath(placeholder); ok {
+		resolution.Path, resolution.SecretKey, resolution.Version = path, secretKey, version
+	}
+	resolution.Backend = selectBackend(annotations, placeholder)[types.AVPBackendAnnotation]
+	for _, stmt := range modifierStmts {
+		resolution.Modifiers = append(resolution.Modifiers, strings.TrimSpace(stmt))
+	}
+	return resolution
+}
+
+// recordingReplacement returns a replacer that only records the placeholders in a value with the given outcome,
+// and returns the value unchanged
+func recordingReplacement(outcome string) func(string, string, Resource) (interface{}, []error) {
+	return func(key, value string, resource Resource) (interface{}, []error) {
+		search := value
+		if resource.Kind == "Secret" {
+			if decoded, ok := decodedSecretValue(value, resource.Annotations); ok {
+				search = decoded
+			}
+		}
+
+		if usesGoTemplate(resource.Annotations) {
+			calls, _ := templateSecretRefs(key, search)
+			for _, call := range calls {
+				resolution := call.resolution(key, resource.Annotations)
+				resolution.Outcome = outcome
+				resource.record(resolution)
+			}
+			return value, nil
+		}
+
+		for _, match := range placeholderRegexFor(resource.Annotations).FindAllString(search, -1) {
+			placeholder, modifierStmts := splitPlaceholder(match)
+			resolution := newResolution(key, placeholder, modifierStmts, resource.Annotations)
+			resolution.Outcome = outcome
+			resource.record(resolution)
+		}
+
+		return value, nil
+	}
+}
+
+// record keeps track of how a placeholder was replaced, for Resources created by NewTemplate
+func (r Resource) record(resolution Resolution) {
+	if r.resolutions != nil {
+		*r.resolutions = append(*r.resolutions, resolution)
+	}
+}
+
+func (r Resource) resolutionCount() int {
+	if r.resolutions == nil {
+		return 0
+	}
+	return len(*r.resolutions)
+}
+
+// markRemoved records that the missing placeholders recorded since `from` were removed due to `avp.kubernetes.io/remove-missing`
+func (r Resource) markRemoved(from int) {
+	if r.resolutions == nil {
+		return
+	}
+	for idx := from; idx < len(*r.resolutions); idx++ {
+		if (*r.resolutions)[idx].Outcome == MissingPlaceholder {
+			(*r.resolutions)[idx].Outcome = RemovedPlaceholder
+		}
+	}
+}
+
+// redact replaces a secret value with a short checksum, so that changes are visible without revealing the value
+func redact(value interface{}) string {
+	var data []byte
+	switch v := value.(type) {
+	case string:
+		data = []byte(v)
+	default:
+		data, _ = json.Marshal(v)
+	}
+	sum := sha256.Sum256(data)
+	return fmt.Sprintf("***sha256:%s***", hex.EncodeToString(sum[:])[:8])
+}
+
 func configReplacement(key, value string, resource Resource) (interface{}, []error) {
 	res, err := genericReplacement(key, value, resource)
 	if err != nil {
@@ -209,23 +475,46 @@ func configReplacement(key, value string, resource Resource) (interface{}, []err
 	}
 
 	// configMap data values must be strings
+	str, ok := scalarString(res)
+	if !ok {
+		return nil, []error{fmt.Errorf("configReplacement: value of key %s must be replaced with a string in a ConfigMap, got %T", key, res)}
+	}
 
 	utils.VerboseToStdErr("key %s comes from ConfigMap manifest, stringifying value %s to fit", key, value)
-	return stringify(res), err
+	return str, err
 }
 
 func secretReplacement(key, value string, resource Resource) (interface{}, []error) {
-	decoded, err := base64.StdEncoding.DecodeString(value)
-	if err == nil && genericPlaceholder.Match(decoded) {
-		res, err := genericReplacement(key, string(decoded), resource)
+	if decoded, ok := decodedSecretValue(value, resource.Annotations); ok {
+		res, err := genericReplacement(key, decoded, resource)
+
+		str, ok := scalarString(res)
+		if !ok {
+			return value, append(err, fmt.Errorf("secretReplacement: value of key %s must be replaced with a string in a Secret, got %T", key, res))
+		}
+
+		// Redacted values are shown as-is, their base64 encoding would hide the redaction
+		if resource.Redact {
+			return str, err
+		}
 
 		utils.VerboseToStdErr("key %s comes from Secret manifest, base64 encoding value %s to fit", key, value)
-		return base64.StdEncoding.EncodeToString([]byte(stringify(res))), err
+		return base64.StdEncoding.EncodeToString([]byte(str)), err
 	}
 
 	return genericReplacement(key, value, resource)
 }
 
+// decodedSecretValue returns the decoded form of a base64 value of a Secret, if it holds placeholders
+// or template actions for a manifest with the given annotations
+func decodedSecretValue(value string, annotations map[string]string) (string, bool) {
+	decoded, err := base64.StdEncoding.DecodeString(value)
+	if err != nil || !(genericPlaceholder.Match(decoded) || usesGoTemplate(annotations) && goTemplateAction.Match(decoded)) {
+		return "", false
+	}
+	return string(decoded), true
+}
+
 func stringify(input interface{}) string {
 	switch input.(type) {
 	case int:
@@ -282,6 +282,58 @@ func TestGenericReplacement_specificPathNoAnnotation(t *testing.T) {
 	assertSuccessfulReplacement(&dummyResource, &expected, t)
 }
 
+// namedBackends selects a MockVault with the backend annotation, like backends.Registry
+type namedBackends map[string]*helpers.MockVault
+
+func (n namedBackends) Login() error {
+	return nil
+}
+func (n namedBackends) GetSecrets(path string, version string, annotations map[string]string) (map[string]interface{}, error) {
+	return n[annotations[types.AVPBackendAnnotation]].GetSecrets(path, version, annotations)
+}
+func (n namedBackends) GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error) {
+	return n[annotations[types.AVPBackendAnnotation]].GetIndividualSecret(path, secret, version, annotations)
+}
+
+func TestGenericReplacement_specificPathNamedBackend(t *testing.T) {
+	defaultVault := helpers.MockVault{}
+	defaultVault.LoadData(map[string]interface{}{
+		"namespace": "default",
+	})
+	corpVault := helpers.MockVault{}
+	corpVault.LoadData(map[string]interface{}{
+		"namespace": "corp",
+	})
+
+	annotations := map[string]string{}
+	dummyResource := Resource{
+		TemplateData: map[string]interface{}{
+			"namespace": "<corp:path:blah/blah#namespace>",
+			"default":   "<path:blah/blah#namespace>",
+		},
+		Data:        map[string]interface{}{},
+		Backend:     namedBackends{"": &defaultVault, "corp": &corpVault},
+		Annotations: annotations,
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"namespace": "corp",
+			"default":   "default",
+		},
+		Data:              map[string]interface{}{},
+		replacementErrors: []error{},
+	}
+
+	assertSuccessfulReplacement(&dummyResource, &expected, t)
+
+	if len(annotations) != 0 {
+		t.Fatalf("expected the manifest annotations to be left alone but got %s", annotations)
+	}
+}
+
 func TestGenericReplacement_multiString(t *testing.T) {
 	dummyResource := Resource{
 		TemplateData: map[string]interface{}{
@@ -503,6 +555,263 @@ func TestGenericReplacement_nestedString(t *testing.T) {
 	assertSuccessfulReplacement(&dummyResource, &expected, t)
 }
 
+func TestGenericReplacement_nestedSlices(t *testing.T) {
+	dummyResource := Resource{
+		TemplateData: map[string]interface{}{
+			"matrix": []interface{}{
+				[]interface{}{"<name>", 1, map[string]interface{}{"tag": "<tag>"}},
+				[]interface{}{[]interface{}{"<namespace>"}},
+			},
+		},
+		Data: map[string]interface{}{
+			"namespace": "default",
+			"name":      "foo",
+			"tag":       "latest",
+		},
+		Annotations: map[string]string{
+			(types.AVPPathAnnotation): "",
+		},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"matrix": []interface{}{
+				[]interface{}{"foo", 1, map[string]interface{}{"tag": "latest"}},
+				[]interface{}{[]interface{}{"default"}},
+			},
+		},
+		Data: map[string]interface{}{
+			"namespace": "default",
+			"name":      "foo",
+			"tag":       "latest",
+		},
+		replacementErrors: []error{},
+	}
+
+	assertSuccessfulReplacement(&dummyResource, &expected, t)
+}
+
+func TestGenericReplacement_keys(t *testing.T) {
+	t.Run("will not replace keys without the annotation", func(t *testing.T) {
+		dummyResource := Resource{
+			TemplateData: map[string]interface{}{
+				"<name>.properties": "<name>",
+			},
+			Data: map[string]interface{}{
+				"name": "foo",
+			},
+			Annotations: map[string]string{
+				(types.AVPPathAnnotation): "",
+			},
+		}
+
+		replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+		expected := Resource{
+			TemplateData: map[string]interface{}{
+				"<name>.properties": "foo",
+			},
+			Data: map[string]interface{}{
+				"name": "foo",
+			},
+			replacementErrors: []error{},
+		}
+
+		assertSuccessfulReplacement(&dummyResource, &expected, t)
+	})
+
+	t.Run("will replace keys at any depth with the annotation", func(t *testing.T) {
+		dummyResource := Resource{
+			TemplateData: map[string]interface{}{
+				"<name>.properties": "<name>",
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{
+						"<team>/owner": "<name>",
+					},
+				},
+				"items": []interface{}{
+					map[string]interface{}{"<replicas>": true},
+				},
+				"unchanged": "value",
+			},
+			Data: map[string]interface{}{
+				"name":     "foo",
+				"team":     "example.com",
+				"replicas": 3,
+			},
+			Annotations: map[string]string{
+				(types.AVPPathAnnotation):        "",
+				(types.AVPReplaceKeysAnnotation): "true",
+			},
+		}
+
+		replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+		expected := Resource{
+			TemplateData: map[string]interface{}{
+				"foo.properties": "foo",
+				"metadata": map[string]interface{}{
+					"labels": map[string]interface{}{
+						"example.com/owner": "foo",
+					},
+				},
+				"items": []interface{}{
+					map[string]interface{}{"3": true},
+				},
+				"unchanged": "value",
+			},
+			Data: map[string]interface{}{
+				"name":     "foo",
+				"team":     "example.com",
+				"replicas": 3,
+			},
+			replacementErrors: []error{},
+		}
+
+		assertSuccessfulReplacement(&dummyResource, &expected, t)
+	})
+
+	t.Run("will allow keys to take the name of a renamed key", func(t *testing.T) {
+		dummyResource := Resource{
+			TemplateData: map[string]interface{}{
+				"<first>":  "1",
+				"<second>": "2",
+			},
+			Data: map[string]interface{}{
+				"first":  "<second>",
+				"second": "other",
+			},
+			Annotations: map[string]string{
+				(types.AVPPathAnnotation):        "",
+				(types.AVPReplaceKeysAnnotation): "true",
+			},
+		}
+
+		replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+		expected := Resource{
+			TemplateData: map[string]interface{}{
+				"<second>": "1",
+				"other":    "2",
+			},
+			Data: map[string]interface{}{
+				"first":  "<second>",
+				"second": "other",
+			},
+			replacementErrors: []error{},
+		}
+
+		assertSuccessfulReplacement(&dummyResource, &expected, t)
+	})
+
+	t.Run("will report keys that collide", func(t *testing.T) {
+		dummyResource := Resource{
+			TemplateData: map[string]interface{}{
+				"<first>":  "1",
+				"<second>": "2",
+				"existing": "3",
+				"<third>":  "4",
+			},
+			Data: map[string]interface{}{
+				"first":  "same",
+				"second": "same",
+				"third":  "existing",
+			},
+			Annotations: map[string]string{
+				(types.AVPPathAnnotation):        "",
+				(types.AVPReplaceKeysAnnotation): "true",
+			},
+		}
+
+		replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+		expected := Resource{
+			TemplateData: map[string]interface{}{
+				"same":     "1",
+				"<second>": "2",
+				"existing": "3",
+				"<third>":  "4",
+			},
+			Data: map[string]interface{}{
+				"first":  "same",
+				"second": "same",
+				"third":  "existing",
+			},
+			replacementErrors: []error{
+				fmt.Errorf("replaceKey: keys <first> and <second> are both replaced with same"),
+				fmt.Errorf("replaceKey: key <third> is replaced with existing, which collides with an existing key"),
+			},
+		}
+
+		assertFailedReplacement(&dummyResource, &expected, t)
+	})
+
+	t.Run("will report keys that are not replaced with scalars", func(t *testing.T) {
+		dummyResource := Resource{
+			TemplateData: map[string]interface{}{
+				"<labels>": "1",
+			},
+			Data: map[string]interface{}{
+				"labels": map[string]interface{}{"app": "foo"},
+			},
+			Annotations: map[string]string{
+				(types.AVPPathAnnotation):        "",
+				(types.AVPReplaceKeysAnnotation): "true",
+			},
+		}
+
+		replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+		expected := Resource{
+			TemplateData: map[string]interface{}{
+				"<labels>": "1",
+			},
+			Data: map[string]interface{}{
+				"labels": map[string]interface{}{"app": "foo"},
+			},
+			replacementErrors: []error{
+				fmt.Errorf("replaceKey: placeholders in key <labels> must be replaced with a string, got map[string]interface {}"),
+			},
+		}
+
+		assertFailedReplacement(&dummyResource, &expected, t)
+	})
+
+	t.Run("will remove keys with missing values with remove-missing", func(t *testing.T) {
+		dummyResource := Resource{
+			Kind: "ConfigMap",
+			TemplateData: map[string]interface{}{
+				"<name>.properties":    "a=b",
+				"<missing>.properties": "c=d",
+			},
+			Data: map[string]interface{}{
+				"name": "foo",
+			},
+			Annotations: map[string]string{
+				(types.AVPPathAnnotation):          "",
+				(types.AVPReplaceKeysAnnotation):   "true",
+				(types.AVPRemoveMissingAnnotation): "true",
+			},
+		}
+
+		replaceInner(&dummyResource, &dummyResource.TemplateData, configReplacement)
+
+		expected := Resource{
+			TemplateData: map[string]interface{}{
+				"foo.properties": "a=b",
+			},
+			Data: map[string]interface{}{
+				"name": "foo",
+			},
+			replacementErrors: []error{},
+		}
+
+		assertSuccessfulReplacement(&dummyResource, &expected, t)
+	})
+}
+
 func TestGenericReplacement_int(t *testing.T) {
 	dummyResource := Resource{
 		TemplateData: map[string]interface{}{
@@ -577,6 +886,159 @@ func TestGenericReplacement_missingValue(t *testing.T) {
 	assertFailedReplacement(&dummyResource, &expected, t)
 }
 
+func TestGenericReplacement_defaultValue(t *testing.T) {
+	mv := helpers.MockVault{}
+	mv.LoadData(map[string]interface{}{
+		"namespace": "default",
+	})
+
+	dummyResource := Resource{
+		Kind: "Deployment",
+		TemplateData: map[string]interface{}{
+			"namespace": "<namespace | default other>",
+			"logLevel":  "--log-level=<path:blah/blah#LOG_LEVEL | default info> --log-format=<format | default json>",
+			"spec": map[string]interface{}{
+				"replicas": "<replicas | default 3 | jsonParse>",
+			},
+			"encoded": "<format | default plain text | base64encode>",
+			"empty":   "<format | default>",
+		},
+		Data: map[string]interface{}{
+			"namespace": "default",
+		},
+		Backend: &mv,
+		Annotations: map[string]string{
+			(types.AVPPathAnnotation): "",
+		},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, genericReplacement)
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"namespace": "default",
+			"logLevel":  "--log-level=info --log-format=json",
+			"spec": map[string]interface{}{
+				"replicas": float64(3),
+			},
+			"encoded": base64.StdEncoding.EncodeToString([]byte("plain text")),
+			"empty":   "",
+		},
+		Data: map[string]interface{}{
+			"namespace": "default",
+		},
+		replacementErrors: []error{},
+	}
+
+	assertSuccessfulReplacement(&dummyResource, &expected, t)
+}
+
+func TestSecretReplacement_defaultValue(t *testing.T) {
+	dummyResource := Resource{
+		Kind: "Secret",
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"LOG_LEVEL": base64.StdEncoding.EncodeToString([]byte("<LOG_LEVEL | default info>")),
+			},
+		},
+		Data: map[string]interface{}{},
+		Annotations: map[string]string{
+			(types.AVPPathAnnotation):          "",
+			(types.AVPRemoveMissingAnnotation): "true",
+		},
+		resolutions: &[]Resolution{},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, secretReplacement)
+
+	if len(*dummyResource.resolutions) != 1 || (*dummyResource.resolutions)[0].Outcome != DefaultedPlaceholder {
+		t.Fatalf("expected the placeholder to be defaulted but got %v", *dummyResource.resolutions)
+	}
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"LOG_LEVEL": base64.StdEncoding.EncodeToString([]byte("info")),
+			},
+		},
+		Data:              map[string]interface{}{},
+		replacementErrors: []error{},
+	}
+
+	assertSuccessfulReplacement(&dummyResource, &expected, t)
+}
+
+func TestConfigReplacement_nonScalar(t *testing.T) {
+	dummyResource := Resource{
+		Kind: "ConfigMap",
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"hosts": "<hosts | split>",
+			},
+		},
+		Data: map[string]interface{}{
+			"hosts": "a,b",
+		},
+		Annotations: map[string]string{
+			(types.AVPPathAnnotation): "",
+		},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, configReplacement)
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"hosts": nil,
+			},
+		},
+		Data: map[string]interface{}{
+			"hosts": "a,b",
+		},
+		replacementErrors: []error{
+			fmt.Errorf("configReplacement: value of key hosts must be replaced with a string in a ConfigMap, got []interface {}"),
+		},
+	}
+
+	assertFailedReplacement(&dummyResource, &expected, t)
+}
+
+func TestSecretReplacement_nonScalar(t *testing.T) {
+	encoded := base64.StdEncoding.EncodeToString([]byte("<hosts | split>"))
+	dummyResource := Resource{
+		Kind: "Secret",
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"hosts": encoded,
+			},
+		},
+		Data: map[string]interface{}{
+			"hosts": "a,b",
+		},
+		Annotations: map[string]string{
+			(types.AVPPathAnnotation): "",
+		},
+	}
+
+	replaceInner(&dummyResource, &dummyResource.TemplateData, secretReplacement)
+
+	expected := Resource{
+		TemplateData: map[string]interface{}{
+			"data": map[string]interface{}{
+				"hosts": encoded,
+			},
+		},
+		Data: map[string]interface{}{
+			"hosts": "a,b",
+		},
+		replacementErrors: []error{
+			fmt.Errorf("secretReplacement: value of key hosts must be replaced with a string in a Secret, got []interface {}"),
+		},
+	}
+
+	assertFailedReplacement(&dummyResource, &expected, t)
+}
+
 func TestSecretReplacement(t *testing.T) {
 	dummyResource := Resource{
 		TemplateData: map[string]interface{}{
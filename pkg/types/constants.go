@@ -14,9 +14,26 @@ const (
 	EnvAvpK8sMountPath     = "AVP_K8S_MOUNT_PATH"
 	EnvAvpMountPath        = "AVP_MOUNT_PATH"
 	EnvAvpK8sTokenPath     = "AVP_K8S_TOKEN_PATH"
+	EnvAvpJWTRole          = "AVP_JWT_ROLE"
+	EnvAvpJWTTokenPath     = "AVP_JWT_TOKEN_PATH"
+	EnvAvpCertRole         = "AVP_CERT_ROLE"
+	EnvAvpIAMRole          = "AVP_IAM_ROLE"
+	EnvAvpIAMServerID      = "AVP_IAM_SERVER_ID"
+	EnvAvpAzureRole        = "AVP_AZURE_ROLE"
+	EnvAvpAzureResource    = "AVP_AZURE_RESOURCE"
+	EnvAvpAzureSubID       = "AVP_AZURE_SUBSCRIPTION_ID"
+	EnvAvpAzureGroup       = "AVP_AZURE_RESOURCE_GROUP"
+	EnvAvpGCPRole          = "AVP_GCP_ROLE"
+	EnvAvpGCPAccount       = "AVP_GCP_SERVICE_ACCOUNT"
+	EnvAvpClientCert       = "AVP_CLIENT_CERT"
+	EnvAvpClientKey        = "AVP_CLIENT_KEY"
+	EnvAvpClientCertPath   = "AVP_CLIENT_CERT_PATH"
+	EnvAvpClientKeyPath    = "AVP_CLIENT_KEY_PATH"
 	EnvAvpIBMAPIKey        = "AVP_IBM_API_KEY"
 	EnvAvpIBMInstanceURL   = "AVP_IBM_INSTANCE_URL"
 	EnvAvpKvVersion        = "AVP_KV_VERSION"
+	EnvAvpVaultNamespace   = "AVP_VAULT_NAMESPACE"
+	EnvAvpVaultAllowWrites = "AVP_VAULT_ALLOW_WRITES"
 	EnvAvpPathPrefix       = "AVP_PATH_PREFIX"
 	EnvAWSRegion           = "AWS_REGION"
 	EnvVaultAddress        = "VAULT_ADDR"
@@ -31,6 +48,8 @@ const (
 	EnvAvpDelineaUser      = "AVP_DELINEA_USER"
 	EnvAvpDelineaPassword  = "AVP_DELINEA_PASSWORD"
 	EnvAvpDelineaDomain    = "AVP_DELINEA_DOMAIN"
+	EnvAvpBackends         = "AVP_BACKENDS"
+	EnvAvpTokenCacheDir    = "AVP_TOKEN_CACHE_DIR"
 
 	// Backend and Auth Constants
 	VaultBackend                = "vault"
@@ -45,6 +64,10 @@ const (
 	KeeperSecretsManagerBackend = "keepersecretsmanager"
 	KubernetesSecretBackend     = "kubernetessecret"
 	K8sAuth                     = "k8s"
+	JWTAuth                     = "jwt"
+	CertAuth                    = "cert"
+	AzureAuth                   = "azure"
+	GCPAuth                     = "gcp"
 	ApproleAuth                 = "approle"
 	GithubAuth                  = "github"
 	TokenAuth                   = "token"
@@ -58,6 +81,7 @@ const (
 	IBMIAMCredentialsType       = "iam_credentials"
 	IBMImportedCertType         = "imported_cert"
 	IBMPublicCertType           = "public_cert"
+	VaultMetadataPrefix         = "@metadata."
 
 	// Supported annotations
 	AVPPathAnnotation          = "avp.kubernetes.io/path"
@@ -65,6 +89,16 @@ const (
 	AVPRemoveMissingAnnotation = "avp.kubernetes.io/remove-missing"
 	AVPSecretVersionAnnotation = "avp.kubernetes.io/secret-version"
 	VaultKVVersionAnnotation   = "avp.kubernetes.io/kv-version"
+	VaultNamespaceAnnotation   = "avp.kubernetes.io/vault-namespace"
+	AVPChecksumFromAnnotation  = "avp.kubernetes.io/checksum-from"
+	AVPChecksumAnnotation      = "avp.kubernetes.io/checksum"
+	AVPBackendAnnotation       = "avp.kubernetes.io/backend"
+	AVPTemplateAnnotation      = "avp.kubernetes.io/template-engine"
+	AVPReplaceKeysAnnotation   = "avp.kubernetes.io/replace-keys"
+	AVPInjectAllAnnotation     = "avp.kubernetes.io/inject-all"
+	AVPInjectIncludeAnnotation = "avp.kubernetes.io/inject-include"
+	AVPInjectExcludeAnnotation = "avp.kubernetes.io/inject-exclude"
+	AVPInjectRenameAnnotation  = "avp.kubernetes.io/inject-rename"
 
 	// Kube Constants
 	ArgoCDNamespace = "argocd"
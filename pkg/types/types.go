@@ -1,6 +1,7 @@
 package types
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/hashicorp/vault/api"
@@ -17,6 +18,35 @@ type Backend interface {
 	GetIndividualSecret(path, secret, version string, annotations map[string]string) (interface{}, error)
 }
 
+// NotFoundError is returned by Backends when there are no secrets at a path, as opposed to failing to look them up
+// Only then does a chain of Backends ask the next one
+type NotFoundError struct {
+	Err error
+}
+
+func (e *NotFoundError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *NotFoundError) Unwrap() error {
+	return e.Err
+}
+
+// IsNotFound reports whether err is, or wraps, a NotFoundError
+func IsNotFound(err error) bool {
+	var notFound *NotFoundError
+	return errors.As(err, &notFound)
+}
+
+// SecretRef is a single lookup against a Backend referenced by a manifest
+// An empty Key refers to all the secrets at Path, as returned by GetSecrets
+type SecretRef struct {
+	Path        string
+	Key         string
+	Version     string
+	Annotations map[string]string
+}
+
 // AuthType is and interface for the supported authentication methods
 type AuthType interface {
 	Authenticate(*api.Client) error
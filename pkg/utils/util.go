@@ -1,127 +1,344 @@
 package utils
 
 import (
+	"crypto/sha256"
 	"crypto/tls"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
 
 	"github.com/hashicorp/vault/api"
 	"github.com/spf13/viper"
 )
 
-func PurgeTokenCache() error {
+const (
+	// tokenExpiryMargin is how long a cached token must remain valid for to be used without renewal, Argo CD stops a run of the plugin after 90 seconds by default
+	tokenExpiryMargin = 90 * time.Second
+)
+
+// cachedToken is the content of a token cache file
+type cachedToken struct {
+	VaultToken   string     `json:"vault_token"`
+	VaultAddress string     `json:"vault_address"`
+	Namespace    string     `json:"namespace,omitempty"`
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+}
+
+// TokenCacheEntry describes a cached token, without revealing the token itself
+type TokenCacheEntry struct {
+	Identifier   string
+	VaultAddress string
+	Namespace    string
+
+	// Nil for tokens that never expire
+	ExpiresAt *time.Time
+
+	token string
+}
+
+// TokenCacheDir returns the directory of the token cache, which is set by AVP_TOKEN_CACHE_DIR and defaults to ~/.avp
+func TokenCacheDir() (string, error) {
+	if dir := viper.GetString("tokenCacheDir"); dir != "" {
+		return dir, nil
+	}
+
 	home, err := os.UserHomeDir()
 	if err != nil {
+		return "", err
+	}
+	return filepath.Join(home, ".avp"), nil
+}
+
+// TokenCacheID returns the identifier of the token obtained by logging in as role with the auth method at mountPath,
+// on the Vault server and namespace of vaultClient
+func TokenCacheID(vaultClient *api.Client, mountPath, role string) string {
+	key := strings.Join([]string{vaultClient.Address(), vaultClient.Namespace(), strings.Trim(mountPath, "/"), role}, "\n")
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+func tokenCachePath(identifier string) (string, error) {
+	dir, err := TokenCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, fmt.Sprintf("token_%s.json", identifier)), nil
+}
+
+// PurgeTokenCache removes every cached token, including those cached in ~/.avp by previous versions
+func PurgeTokenCache() error {
+	dir, err := TokenCacheDir()
+	if err != nil {
+		return err
+	}
+
+	if err := removeTokenFiles(dir, "token_*.json"); err != nil {
 		return err
 	}
+	return removeLegacyTokens(dir)
+}
+
+// legacyTokenPatterns match the plaintext token files that previous versions wrote to ~/.avp, readable by every user
+var legacyTokenPatterns = []string{"kubernetes_config.json", "github_config.json", "approle_*_config.json", "userpass_*_config.json"}
 
-	avpConfigFolderPath := filepath.Join(home, ".avp")
+// defaultTokenCacheDir reports whether the token cache is ~/.avp, which the plugin owns,
+// rather than a directory set with AVP_TOKEN_CACHE_DIR that may hold other files
+func defaultTokenCacheDir() bool {
+	return viper.GetString("tokenCacheDir") == ""
+}
 
-	err = os.RemoveAll(avpConfigFolderPath)
+// removeLegacyTokens removes the token files of previous versions from dir, when it is the default ~/.avp
+func removeLegacyTokens(dir string) error {
+	if !defaultTokenCacheDir() {
+		return nil
+	}
+	for _, pattern := range legacyTokenPatterns {
+		if err := removeTokenFiles(dir, pattern); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// removeTokenFiles removes the files matching pattern in the token cache dir
+func removeTokenFiles(dir, pattern string) error {
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
 	if err != nil {
 		return err
 	}
+	for _, file := range files {
+		if err := os.Remove(file); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func ReadExistingToken(identifier string) ([]byte, error) {
-	home, err := os.UserHomeDir()
+// ListTokenCache returns every cached token
+func ListTokenCache() ([]TokenCacheEntry, error) {
+	dir, err := TokenCacheDir()
 	if err != nil {
 		return nil, err
 	}
 
-	avpConfigPath := filepath.Join(home, ".avp", fmt.Sprintf("%s_config.json", identifier))
-	if _, err := os.Stat(avpConfigPath); err != nil {
+	files, err := filepath.Glob(filepath.Join(dir, "token_*.json"))
+	if err != nil {
 		return nil, err
 	}
 
-	// Open our jsonFile
-	jsonFile, err := os.Open(avpConfigPath)
+	entries := make([]TokenCacheEntry, 0, len(files))
+	for _, file := range files {
+		byteValue, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var cached cachedToken
+		err = json.Unmarshal(byteValue, &cached)
+		if err != nil {
+			return nil, fmt.Errorf("Could not read cached token %s: %s", file, err.Error())
+		}
+
+		entries = append(entries, TokenCacheEntry{
+			Identifier:   strings.TrimSuffix(strings.TrimPrefix(filepath.Base(file), "token_"), ".json"),
+			VaultAddress: cached.VaultAddress,
+			Namespace:    cached.Namespace,
+			ExpiresAt:    cached.ExpiresAt,
+			token:        cached.VaultToken,
+		})
+	}
+	return entries, nil
+}
+
+// RemoveCachedToken removes the cached token of identifier
+func RemoveCachedToken(identifier string) error {
+	if filepath.Base(identifier) != identifier {
+		return fmt.Errorf("Invalid token cache identifier %s", identifier)
+	}
+
+	path, err := tokenCachePath(identifier)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("No cached token %s", identifier)
+	}
+	return err
+}
+
+// LookupSelf looks up the cached token with the Vault server it was obtained from, using a client created from config
+// for TLS settings like the client certificate. The address of the config is overridden by the one of the token
+func (e TokenCacheEntry) LookupSelf(config *api.Config) (*api.Secret, error) {
+	if e.VaultAddress != "" {
+		config.Address = e.VaultAddress
+	}
+
+	vaultClient, err := api.NewClient(config)
 	if err != nil {
 		return nil, err
 	}
-	// defer the closing of our jsonFile so that we can parse it later on
-	defer jsonFile.Close()
+	vaultClient.SetToken(e.token)
+	vaultClient.SetNamespace(e.Namespace)
+
+	return vaultClient.Auth().Token().LookupSelf()
+}
 
-	byteValue, err := io.ReadAll(jsonFile)
+func ReadExistingToken(identifier string) ([]byte, error) {
+	avpConfigPath, err := tokenCachePath(identifier)
 	if err != nil {
 		return nil, err
 	}
 
-	return byteValue, nil
+	return os.ReadFile(avpConfigPath)
 }
 
-// LoginWithCachedToken takes a VaultType interface, the auth types and an identifier of the token
-// It then tries to log in with the matching previously cached token,
-// And sets the token in the client
+// LoginWithCachedToken tries to log in with the previously cached token matching identifier,
+// renewing it if it is about to expire, and sets the token in the client
 func LoginWithCachedToken(vaultClient *api.Client, identifier string) error {
 	if viper.GetBool("disableCache") {
 		return fmt.Errorf("Token cache feature is disabled")
-	}	else {
-		byteValue, err := ReadExistingToken(identifier)
-		if err != nil {
-			return err
-		}
+	}
 
-		var result map[string]interface{}
-		err = json.Unmarshal([]byte(byteValue), &result)
-		if err != nil {
-			return err
-		}
+	byteValue, err := ReadExistingToken(identifier)
+	if err != nil {
+		return err
+	}
 
-		vaultClient.SetToken(result["vault_token"].(string))
-		_, err = vaultClient.Auth().Token().LookupSelf()
-		if err != nil {
-			return err
-		}
+	var cached cachedToken
+	err = json.Unmarshal(byteValue, &cached)
+	if err != nil {
+		return err
+	}
+	if cached.ExpiresAt != nil && !time.Now().Before(*cached.ExpiresAt) {
+		return fmt.Errorf("Cached token expired at %s", cached.ExpiresAt.Format(time.RFC3339))
+	}
 
+	vaultClient.SetToken(cached.VaultToken)
+	self, err := vaultClient.Auth().Token().LookupSelf()
+	if err != nil {
+		return err
+	}
+
+	ttl, err := self.TokenTTL()
+	if err != nil {
+		return err
+	}
+	// Tokens without a TTL never expire
+	if ttl == 0 || ttl >= tokenExpiryMargin {
 		return nil
 	}
+
+	renewable, err := self.TokenIsRenewable()
+	if err != nil {
+		return err
+	}
+	if !renewable {
+		return fmt.Errorf("Cached token expires in %s and cannot be renewed", ttl)
+	}
+
+	VerboseToStdErr("Hashicorp Vault renewing cached token expiring in %s", ttl)
+	renewed, err := vaultClient.Auth().Token().RenewSelf(0)
+	if err != nil {
+		return fmt.Errorf("Could not renew cached token: %s", err.Error())
+	}
+	if renewed == nil || renewed.Auth == nil || time.Duration(renewed.Auth.LeaseDuration)*time.Second < tokenExpiryMargin {
+		return fmt.Errorf("Cached token reached its maximum TTL")
+	}
+
+	// If we cannot record the new expiry, the token will just be renewed again next time
+	if err = writeToken(vaultClient, identifier, cached.VaultToken, renewed.Auth.LeaseDuration); err != nil {
+		VerboseToStdErr("Hashicorp Vault cannot cache renewed token for future runs: %v", err)
+	}
+
+	return nil
 }
 
-// SetToken attmepts to set the vault token on the vault api client
-// and then attempts to write that token to a file to be used later
+// SetToken sets the vault token of auth on the vault api client
+// and then attempts to write that token, along with its expiry, to a file to be used later
 // If this method fails we do not want to stop the process
-func SetToken(vaultClient *api.Client, identifier string, token string) error {
+func SetToken(vaultClient *api.Client, identifier string, auth *api.SecretAuth) error {
 	// We want to set the token first
-	vaultClient.SetToken(token)
+	vaultClient.SetToken(auth.ClientToken)
 
 	if viper.GetBool("disableCache") {
 		return fmt.Errorf("Token cache feature is disabled")
-	}	else {
-		home, err := os.UserHomeDir()
-		if err != nil {
-			return fmt.Errorf("Could not access home directory: %s", err.Error())
-		}
+	}
 
-		path := filepath.Join(home, ".avp")
-		if _, err := os.Stat(path); os.IsNotExist(err) {
-			err := os.Mkdir(path, 0755)
-			if err != nil {
-				return fmt.Errorf("Could not create avp directory: %s", err.Error())
-			}
-		}
+	return writeToken(vaultClient, identifier, auth.ClientToken, auth.LeaseDuration)
+}
 
-		data := map[string]interface{}{
-			"vault_token": token,
-		}
-		file, err := json.MarshalIndent(data, "", " ")
+// writeToken atomically writes the token cache file of identifier, only readable by the current user
+func writeToken(vaultClient *api.Client, identifier string, token string, leaseDuration int) error {
+	path, err := tokenCachePath(identifier)
+	if err != nil {
+		return fmt.Errorf("Could not find token cache directory: %s", err.Error())
+	}
+
+	dir := filepath.Dir(path)
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		return fmt.Errorf("Could not create token cache directory: %s", err.Error())
+	}
+
+	if defaultTokenCacheDir() {
+		// Previous versions created ~/.avp readable by every user, and left their tokens in it
+		err = os.Chmod(dir, 0700)
 		if err != nil {
-			return fmt.Errorf("Could not marshal token data: %s", err.Error())
+			return fmt.Errorf("Could not restrict the permissions of token cache directory: %s", err.Error())
 		}
-		err = os.WriteFile(filepath.Join(path, fmt.Sprintf("%s_config.json", identifier)), file, 0644)
+		err = removeLegacyTokens(dir)
 		if err != nil {
-			return fmt.Errorf("Could not write token to file, will need to login to Vault on subsequent runs: %s", err.Error())
+			return fmt.Errorf("Could not remove legacy token files: %s", err.Error())
 		}
+	} else if info, err := os.Stat(dir); err == nil && info.Mode().Perm()&0077 != 0 {
+		// The directory is not ours to change, the token files themselves are still only readable by the current user
+		VerboseToStdErr("Token cache directory %s is accessible by other users, which can see which tokens are cached", dir)
+	}
 
-		return nil
+	data := cachedToken{
+		VaultToken:   token,
+		VaultAddress: vaultClient.Address(),
+		Namespace:    vaultClient.Namespace(),
+	}
+	if leaseDuration > 0 {
+		expiresAt := time.Now().Add(time.Duration(leaseDuration) * time.Second).UTC()
+		data.ExpiresAt = &expiresAt
+	}
+	file, err := json.MarshalIndent(data, "", " ")
+	if err != nil {
+		return fmt.Errorf("Could not marshal token data: %s", err.Error())
+	}
+
+	// Readers never see a partially written file, as the complete file is renamed over the previous one
+	tmp, err := os.CreateTemp(dir, ".token_*.tmp")
+	if err != nil {
+		return fmt.Errorf("Could not write token to file, will need to login to Vault on subsequent runs: %s", err.Error())
 	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(file)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0600)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), path)
+	}
+	if err != nil {
+		return fmt.Errorf("Could not write token to file, will need to login to Vault on subsequent runs: %s", err.Error())
+	}
+
+	return nil
 }
 
 func DefaultHttpClient() *http.Client {
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
 	"reflect"
@@ -13,54 +14,23 @@ import (
 
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/helpers"
 	"github.com/argoproj-labs/argocd-vault-plugin/pkg/utils"
+	"github.com/hashicorp/vault/api"
+	"github.com/spf13/viper"
 )
 
-func writeToken(identifier string, token string) error {
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return err
-	}
-	path := filepath.Join(home, ".avp")
-	os.Mkdir(path, 0755)
-	data := map[string]interface{}{
-		"vault_token": token,
-	}
-	file, _ := json.MarshalIndent(data, "", " ")
-	err = os.WriteFile(filepath.Join(path, fmt.Sprintf("%s_config.json", identifier)), file, 0644)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func removeToken() error {
-	home, _ := os.UserHomeDir()
-	path := filepath.Join(home, ".avp")
-	err := os.RemoveAll(path)
-	if err != nil {
-		return err
-	}
-	return nil
-}
-
-func readToken(identifier string) interface{} {
-	home, _ := os.UserHomeDir()
-	path := filepath.Join(home, ".avp", fmt.Sprintf("%s_config.json", identifier))
-	dat, _ := os.ReadFile(path)
-	var result map[string]interface{}
-	json.Unmarshal([]byte(dat), &result)
-	return result["vault_token"]
-}
-
 func TestCheckExistingToken(t *testing.T) {
+	viper.Set("tokenCacheDir", t.TempDir())
+	defer viper.Set("tokenCacheDir", "")
+
 	ln, client, roottoken := helpers.CreateTestVault(t)
 	defer ln.Close()
 
 	t.Run("will set token if valid", func(t *testing.T) {
-		err := writeToken("test", roottoken)
+		err := utils.SetToken(client, "test", &api.SecretAuth{ClientToken: roottoken})
 		if err != nil {
 			t.Fatal(err)
 		}
+		client.ClearToken()
 
 		err = utils.LoginWithCachedToken(client, "test")
 		if err != nil {
@@ -72,7 +42,7 @@ func TestCheckExistingToken(t *testing.T) {
 			t.Errorf("expected: %s, got: %s.", roottoken, token)
 		}
 
-		err = removeToken()
+		err = utils.PurgeTokenCache()
 		if err != nil {
 			t.Fatal(err)
 		}
@@ -87,24 +57,274 @@ func TestCheckExistingToken(t *testing.T) {
 			t.Fatal(err)
 		}
 
-		dir, _ := os.UserHomeDir()
-		expected := fmt.Sprintf("stat %s/.avp/test_config.json: no such file or directory", dir)
+		dir, _ := utils.TokenCacheDir()
+		expected := fmt.Sprintf("open %s/token_test.json: no such file or directory", dir)
 		if err.Error() != expected {
 			t.Errorf("expected: %s, got: %s.", expected, err.Error())
 		}
 	})
+
+	t.Run("will throw an error if the token expired", func(t *testing.T) {
+		dir, _ := utils.TokenCacheDir()
+		err := os.WriteFile(filepath.Join(dir, "token_test.json"), []byte(`{"vault_token": "`+roottoken+`", "expires_at": "2020-01-01T00:00:00Z"}`), 0600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = utils.LoginWithCachedToken(client, "test")
+		expected := "Cached token expired at 2020-01-01T00:00:00Z"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected: %s, got: %v.", expected, err)
+		}
+	})
+
+	t.Run("will throw an error if the token cannot be renewed for long enough", func(t *testing.T) {
+		client.SetToken(roottoken)
+		secret, err := client.Auth().Token().Create(&api.TokenCreateRequest{
+			Policies: []string{"default"},
+			TTL:      "1m",
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = utils.SetToken(client, "test", secret.Auth)
+		if err != nil {
+			t.Fatal(err)
+		}
+		client.ClearToken()
+
+		err = utils.LoginWithCachedToken(client, "test")
+		expected := "Cached token reached its maximum TTL"
+		if err == nil || err.Error() != expected {
+			t.Errorf("expected: %s, got: %v.", expected, err)
+		}
+	})
+
+	t.Run("will renew a token about to expire", func(t *testing.T) {
+		var renewed bool
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/v1/auth/token/lookup-self":
+				w.Write([]byte(`{"data": {"ttl": 30, "renewable": true}}`))
+			case "/v1/auth/token/renew-self":
+				renewed = true
+				w.Write([]byte(`{"auth": {"client_token": "s.renewable", "lease_duration": 3600, "renewable": true}}`))
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		defer server.Close()
+
+		client, err := api.NewClient(&api.Config{Address: server.URL})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = utils.SetToken(client, "renewable", &api.SecretAuth{ClientToken: "s.renewable", LeaseDuration: 30})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		err = utils.LoginWithCachedToken(client, "renewable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !renewed {
+			t.Fatalf("expected the token to be renewed")
+		}
+
+		data, err := utils.ReadExistingToken("renewable")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var result map[string]interface{}
+		json.Unmarshal(data, &result)
+		expiresAt, err := time.Parse(time.RFC3339, fmt.Sprint(result["expires_at"]))
+		if err != nil || time.Until(expiresAt) <= 59*time.Minute {
+			t.Errorf("expected the renewed token to expire in an hour, got: %v.", result["expires_at"])
+		}
+	})
 }
 
 func TestSetToken(t *testing.T) {
+	viper.Set("tokenCacheDir", filepath.Join(t.TempDir(), "cache"))
+	defer viper.Set("tokenCacheDir", "")
+
+	cluster, _, _ := helpers.CreateTestAppRoleVault(t)
+	defer cluster.Cleanup()
+
+	err := utils.SetToken(cluster.Cores[0].Client, "test", &api.SecretAuth{ClientToken: "token", LeaseDuration: 3600})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, _ := utils.TokenCacheDir()
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0700 {
+		t.Errorf("expected directory mode 0700, got: %o", info.Mode().Perm())
+	}
+
+	info, err = os.Stat(filepath.Join(dir, "token_test.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected file mode 0600, got: %o", info.Mode().Perm())
+	}
+
+	data, err := utils.ReadExistingToken("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var result map[string]interface{}
+	json.Unmarshal(data, &result)
+	if result["vault_token"] != "token" {
+		t.Errorf("expected: token, got: %v.", result["vault_token"])
+	}
+	expiresAt, err := time.Parse(time.RFC3339, fmt.Sprint(result["expires_at"]))
+	if err != nil || time.Until(expiresAt) <= 59*time.Minute {
+		t.Errorf("expected the token to expire in an hour, got: %v.", result["expires_at"])
+	}
+
+	err = utils.PurgeTokenCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := utils.ReadExistingToken("test"); err == nil {
+		t.Errorf("expected the token cache to be purged")
+	}
+}
+
+func TestSetTokenLegacyCache(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	dir := filepath.Join(home, ".avp")
+
+	// Previous versions created ~/.avp with mode 0755 and wrote plaintext tokens with mode 0644
+	if err := os.Mkdir(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	legacy := filepath.Join(dir, "approle_role_config.json")
+	if err := os.WriteFile(legacy, []byte(`{"vault_token":"token"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cluster, _, _ := helpers.CreateTestAppRoleVault(t)
+	defer cluster.Cleanup()
+
+	t.Run("will restrict the directory and remove legacy tokens", func(t *testing.T) {
+		err := utils.SetToken(cluster.Cores[0].Client, "test", &api.SecretAuth{ClientToken: "token"})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		info, err := os.Stat(dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if info.Mode().Perm() != 0700 {
+			t.Errorf("expected directory mode 0700, got: %o", info.Mode().Perm())
+		}
+		if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+			t.Errorf("expected the legacy token to be removed, got: %v", err)
+		}
+	})
+
+	t.Run("will purge legacy tokens", func(t *testing.T) {
+		if err := os.WriteFile(legacy, []byte(`{"vault_token":"token"}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		err := utils.PurgeTokenCache()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := os.Stat(legacy); !os.IsNotExist(err) {
+			t.Errorf("expected the legacy token to be purged, got: %v", err)
+		}
+		if _, err := utils.ReadExistingToken("test"); err == nil {
+			t.Errorf("expected the token cache to be purged")
+		}
+	})
+}
+
+func TestSetTokenCustomCacheDir(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Chmod(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("tokenCacheDir", dir)
+	defer viper.Set("tokenCacheDir", "")
+
+	// Files that are not tokens of previous versions, even with similar names, belong to someone else
+	unrelated := []string{
+		filepath.Join(dir, "foo_config.json"),
+		filepath.Join(dir, "kubernetes_config.json"),
+	}
+	for _, file := range unrelated {
+		if err := os.WriteFile(file, []byte(`{}`), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
 	cluster, _, _ := helpers.CreateTestAppRoleVault(t)
 	defer cluster.Cleanup()
 
-	utils.SetToken(cluster.Cores[0].Client, "test", "token")
+	err := utils.SetToken(cluster.Cores[0].Client, "test", &api.SecretAuth{ClientToken: "token"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = utils.PurgeTokenCache()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Errorf("expected directory mode 0755 to be left as is, got: %o", info.Mode().Perm())
+	}
+	for _, file := range unrelated {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("expected %s to be left as is, got: %v", file, err)
+		}
+	}
+	if _, err := utils.ReadExistingToken("test"); err == nil {
+		t.Errorf("expected the token cache to be purged")
+	}
+}
 
-	err := removeToken()
+func TestTokenCacheID(t *testing.T) {
+	client, err := api.NewClient(&api.Config{Address: "https://vault.example.com"})
 	if err != nil {
 		t.Fatal(err)
 	}
+	other, err := api.NewClient(&api.Config{Address: "https://other.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	id := utils.TokenCacheID(client, "auth/kubernetes", "argocd")
+	if utils.TokenCacheID(client, "/auth/kubernetes/", "argocd") != id {
+		t.Errorf("expected the same identifier regardless of slashes in the mount path")
+	}
+
+	for name, otherID := range map[string]string{
+		"address":   utils.TokenCacheID(other, "auth/kubernetes", "argocd"),
+		"namespace": utils.TokenCacheID(client.WithNamespace("team-a"), "auth/kubernetes", "argocd"),
+		"mount":     utils.TokenCacheID(client, "auth/k8s", "argocd"),
+		"role":      utils.TokenCacheID(client, "auth/kubernetes", "other"),
+	} {
+		if otherID == id {
+			t.Errorf("expected a different identifier for another %s", name)
+		}
+	}
 }
 
 func TestDefaultHTTPClient(t *testing.T) {